@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// config is the file given by -config. Each field is applied to the flag of
+// the same name unless the flag is set by command line or environment variable.
+type config struct {
+	Site        string `yaml:"site"`
+	Depth       *int   `yaml:"depth"`
+	Parallelism *int   `yaml:"parallelism"`
+	LimitRule   struct {
+		AllowedHosts    []string `yaml:"allowed_hosts"`
+		DisallowedHosts []string `yaml:"disallowed_hosts"`
+		AllowURL        []string `yaml:"allow_url"`
+		DenyURL         []string `yaml:"deny_url"`
+		AllowedSchemes  []string `yaml:"allowed_schemes"`
+		StayUnderSeed   *bool    `yaml:"stay_under_seed"`
+	} `yaml:"limit_rule"`
+	Trap struct {
+		MaxPathDepth        *int `yaml:"max_path_depth"`
+		MaxRepeatedSegments *int `yaml:"max_repeated_segments"`
+		MaxQueryParams      *int `yaml:"max_query_params"`
+		MaxURLLength        *int `yaml:"max_url_length"`
+		MaxURLsPerHost      *int `yaml:"max_urls_per_host"`
+		MaxURLsPerPattern   *int `yaml:"max_urls_per_pattern"`
+	} `yaml:"trap"`
+	Budget struct {
+		MaxPages        *int   `yaml:"max_pages"`
+		MaxBytes        *int64 `yaml:"max_bytes"`
+		MaxDuration     string `yaml:"max_duration"`
+		MaxPagesPerHost *int   `yaml:"max_pages_per_host"`
+	} `yaml:"budget"`
+	Fetcher struct {
+		HeadlessChrome       *bool    `yaml:"headless_chrome"`
+		BlockPrivateNetworks *bool    `yaml:"block_private_networks"`
+		AllowCIDRs           []string `yaml:"allow_cidrs"`
+		DenyCIDRs            []string `yaml:"deny_cidrs"`
+	} `yaml:"fetcher"`
+	Output struct {
+		Dir       string `yaml:"dir"`
+		Archive   string `yaml:"archive"`
+		Format    string `yaml:"format"`
+		Tables    string `yaml:"tables"`
+		WriteMeta *bool  `yaml:"write_meta"`
+		Snapshot  *bool  `yaml:"snapshot"`
+	} `yaml:"output"`
+}
+
+// stringList is a flag which can be given multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// loadConfig reads the config file at path.
+func loadConfig(path string) (*config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := new(config)
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("Invalid config: %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// flags returns values of the config by flag name.
+func (c *config) flags() map[string][]string {
+	m := map[string][]string{}
+	str := func(name, s string) {
+		if s != "" {
+			m[name] = []string{s}
+		}
+	}
+	list := func(name string, l []string) {
+		if len(l) > 0 {
+			m[name] = l
+		}
+	}
+	integer := func(name string, i *int) {
+		if i != nil {
+			m[name] = []string{strconv.Itoa(*i)}
+		}
+	}
+	boolean := func(name string, b *bool) {
+		if b != nil {
+			m[name] = []string{strconv.FormatBool(*b)}
+		}
+	}
+	str("site", c.Site)
+	integer("depth", c.Depth)
+	integer("parallelism", c.Parallelism)
+	if len(c.LimitRule.AllowedHosts) > 0 {
+		m["allowed_hosts"] = []string{strings.Join(c.LimitRule.AllowedHosts, ",")}
+	}
+	if len(c.LimitRule.DisallowedHosts) > 0 {
+		m["disallowed_hosts"] = []string{strings.Join(c.LimitRule.DisallowedHosts, ",")}
+	}
+	list("allow_url", c.LimitRule.AllowURL)
+	list("deny_url", c.LimitRule.DenyURL)
+	if len(c.LimitRule.AllowedSchemes) > 0 {
+		m["allowed_schemes"] = []string{strings.Join(c.LimitRule.AllowedSchemes, ",")}
+	}
+	boolean("stay_under_seed", c.LimitRule.StayUnderSeed)
+	integer("max_path_depth", c.Trap.MaxPathDepth)
+	integer("max_repeated_segments", c.Trap.MaxRepeatedSegments)
+	integer("max_query_params", c.Trap.MaxQueryParams)
+	integer("max_url_length", c.Trap.MaxURLLength)
+	integer("max_urls_per_host", c.Trap.MaxURLsPerHost)
+	integer("max_urls_per_pattern", c.Trap.MaxURLsPerPattern)
+	integer("max_pages", c.Budget.MaxPages)
+	if c.Budget.MaxBytes != nil {
+		m["max_bytes"] = []string{strconv.FormatInt(*c.Budget.MaxBytes, 10)}
+	}
+	str("max_duration", c.Budget.MaxDuration)
+	integer("max_pages_per_host", c.Budget.MaxPagesPerHost)
+	boolean("headless_chrome", c.Fetcher.HeadlessChrome)
+	boolean("block_private_networks", c.Fetcher.BlockPrivateNetworks)
+	if len(c.Fetcher.AllowCIDRs) > 0 {
+		m["allow_cidrs"] = []string{strings.Join(c.Fetcher.AllowCIDRs, ",")}
+	}
+	if len(c.Fetcher.DenyCIDRs) > 0 {
+		m["deny_cidrs"] = []string{strings.Join(c.Fetcher.DenyCIDRs, ",")}
+	}
+	str("output_dir", c.Output.Dir)
+	str("output_archive", c.Output.Archive)
+	str("output_format", c.Output.Format)
+	str("output_tables", c.Output.Tables)
+	boolean("write_meta", c.Output.WriteMeta)
+	boolean("snapshot", c.Output.Snapshot)
+	return m
+}
+
+// loadFlags sets flags of fs not given by command line from environment
+// variables, and then flags given by neither of them from the config file
+// at path. Config file is not read if path is empty.
+func loadFlags(fs *flag.FlagSet, path string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] {
+			return
+		}
+		if s := os.Getenv(strings.ToUpper(f.Name)); s != "" {
+			if e := fs.Set(f.Name, s); e != nil && err == nil {
+				err = fmt.Errorf("Invalid value %q for %s: %v", s, strings.ToUpper(f.Name), e)
+			}
+			set[f.Name] = true
+		}
+	})
+	if err != nil || path == "" {
+		return err
+	}
+
+	c, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.flags() {
+		if set[name] {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("Invalid config: unknown flag %s", name)
+		}
+		for _, v := range values {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("Invalid config: %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
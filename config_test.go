@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testFlags is the values of flags set by loadFlags.
+type testFlags struct {
+	Site           string
+	Depth          int
+	Parallelism    int
+	MaxBytes       int64
+	MaxDuration    time.Duration
+	StayUnderSeed  bool
+	AllowedHosts   string
+	AllowURLs      stringList
+	AllowedSchemes string
+}
+
+func newTestFlagSet(v *testFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&v.Site, "site", "", "")
+	fs.IntVar(&v.Depth, "depth", 1, "")
+	fs.IntVar(&v.Parallelism, "parallelism", 10, "")
+	fs.Int64Var(&v.MaxBytes, "max_bytes", 0, "")
+	fs.DurationVar(&v.MaxDuration, "max_duration", 0, "")
+	fs.BoolVar(&v.StayUnderSeed, "stay_under_seed", false, "")
+	fs.StringVar(&v.AllowedHosts, "allowed_hosts", "", "")
+	fs.Var(&v.AllowURLs, "allow_url", "")
+	fs.StringVar(&v.AllowedSchemes, "allowed_schemes", "http,https", "")
+	return fs
+}
+
+func TestLoadFlags(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		want testFlags
+	}{
+		{
+			name: "precedence",
+			yaml: "site: https://file.com/\ndepth: 3\nparallelism: 4\n",
+			env:  map[string]string{"SITE": "https://env.com/", "DEPTH": "2"},
+			args: []string{"-site", "https://cli.com/"},
+			want: testFlags{Site: "https://cli.com/", Depth: 2, Parallelism: 4, AllowedSchemes: "http,https"},
+		},
+		{
+			name: "lists",
+			yaml: "limit_rule:\n  allowed_hosts: [a.com, b.com]\n  allow_url: ['^/a', '^/b']\n  allowed_schemes: [https]\n",
+			want: testFlags{
+				Depth: 1, Parallelism: 10,
+				AllowedHosts: "a.com,b.com", AllowURLs: stringList{"^/a", "^/b"}, AllowedSchemes: "https",
+			},
+		},
+		{
+			name: "list given by command line",
+			yaml: "limit_rule:\n  allow_url: ['^/a', '^/b']\n",
+			args: []string{"-allow_url", "^/c"},
+			want: testFlags{Depth: 1, Parallelism: 10, AllowURLs: stringList{"^/c"}, AllowedSchemes: "http,https"},
+		},
+		{
+			name: "types",
+			yaml: "depth: 5\nlimit_rule:\n  stay_under_seed: true\nbudget:\n  max_bytes: 1048576\n  max_duration: 1m30s\n",
+			want: testFlags{
+				Depth: 5, Parallelism: 10, MaxBytes: 1 << 20, MaxDuration: 90 * time.Second,
+				StayUnderSeed: true, AllowedSchemes: "http,https",
+			},
+		},
+		{
+			name: "environment overrides false of file",
+			yaml: "limit_rule:\n  stay_under_seed: false\n",
+			env:  map[string]string{"STAY_UNDER_SEED": "true"},
+			want: testFlags{Depth: 1, Parallelism: 10, StayUnderSeed: true, AllowedSchemes: "http,https"},
+		},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(tempDir, "config.yaml")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(tt.yaml), 0644))
+		for k, v := range tt.env {
+			os.Setenv(k, v)
+		}
+		var got testFlags
+		fs := newTestFlagSet(&got)
+		assert.NoError(t, fs.Parse(tt.args), tt.name)
+		assert.NoError(t, loadFlags(fs, path), tt.name)
+		assert.Equal(t, tt.want, got, tt.name)
+		for k := range tt.env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestLoadFlagsError(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+
+	tests := map[string]string{
+		"unknown key":        "site: https://test.com/\nsites: https://test.com/\n",
+		"unknown nested key": "limit_rule:\n  allowed_host: [a.com]\n",
+		"malformed yaml":     "site: [https://test.com/\n",
+		"invalid int":        "depth: deep\n",
+		"invalid duration":   "budget:\n  max_duration: 5 minutes\n",
+	}
+	for name, yaml := range tests {
+		path := filepath.Join(tempDir, "config.yaml")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(yaml), 0644))
+		var got testFlags
+		assert.Error(t, loadFlags(newTestFlagSet(&got), path), name)
+	}
+
+	var got testFlags
+	assert.Error(t, loadFlags(newTestFlagSet(&got), filepath.Join(tempDir, "missing.yaml")))
+
+	os.Setenv("DEPTH", "deep")
+	defer os.Unsetenv("DEPTH")
+	assert.Error(t, loadFlags(newTestFlagSet(&got), ""))
+}
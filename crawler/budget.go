@@ -0,0 +1,170 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Budgets which stop crawling, reported by StoppedBy of Stats.
+const (
+	BudgetMaxPages    = "max_pages"
+	BudgetMaxBytes    = "max_bytes"
+	BudgetMaxDuration = "max_duration"
+)
+
+// ErrBudgetExceeded is the error thrown if the URL is not visited because
+// its host has reached the max pages per host.
+var ErrBudgetExceeded = errors.New("Budget exceeded")
+
+// Budget is the limits of crawling. When MaxPages, MaxBytes or MaxDuration
+// is reached, crawler stops visiting new pages and finishes pages in flight.
+// Zero value of each limit means no limit.
+type Budget struct {
+	// MaxPages is the max number of pages requested.
+	MaxPages int
+	// MaxBytes is the max total size of response bodies.
+	MaxBytes int64
+	// MaxDuration is the max time from the start of crawling.
+	MaxDuration time.Duration
+	// MaxPagesPerHost is the max number of pages requested on each host.
+	// Other pages of the host are skipped, and crawling goes on. Unlike
+	// MaxURLsPerHost of TrapRule, hosts reaching it are reported by
+	// HostsOverBudget of Stats.
+	MaxPagesPerHost int
+}
+
+// Stats is the summary of crawling.
+type Stats struct {
+	// Pages is the number of pages requested.
+	Pages int
+	// Bytes is the total size of response bodies.
+	Bytes int64
+	// Duration is the time from the start to the end of crawling.
+	Duration time.Duration
+	// StoppedBy is the budget that stopped crawling, such as BudgetMaxPages.
+	// It is empty if crawling is not stopped by a budget.
+	StoppedBy string
+	// HostsOverBudget is the hosts that reached the max pages per host.
+	HostsOverBudget []string
+}
+
+// budgetState is the usage of the budget. It is guarded by mux of Crawler.
+type budgetState struct {
+	startedAt  time.Time
+	finishedAt time.Time
+	pages      int
+	bytes      int64
+	hostPages  map[string]int
+	stoppedBy  string
+}
+
+// SetBudget sets the limits of crawling. By default, there are no limits
+// other than the max depth.
+func (c *Crawler) SetBudget(b Budget) {
+	c.budget = b
+}
+
+// Stats returns the summary of crawling.
+func (c *Crawler) Stats() Stats {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	s := Stats{
+		Pages:     c.usage.pages,
+		Bytes:     c.usage.bytes,
+		StoppedBy: c.usage.stoppedBy,
+	}
+	if !c.usage.startedAt.IsZero() {
+		end := c.usage.finishedAt
+		if end.IsZero() {
+			end = time.Now()
+		}
+		s.Duration = end.Sub(c.usage.startedAt)
+	}
+	for host, n := range c.usage.hostPages {
+		if c.budget.MaxPagesPerHost > 0 && n >= c.budget.MaxPagesPerHost {
+			s.HostsOverBudget = append(s.HostsOverBudget, host)
+		}
+	}
+	sort.Strings(s.HostsOverBudget)
+	return s
+}
+
+// startBudget records the start of crawling and stops crawling when
+// MaxDuration is passed. The returned function must be called at the end.
+func (c *Crawler) startBudget() func() {
+	c.mux.Lock()
+	if c.usage.startedAt.IsZero() {
+		c.usage.startedAt = time.Now()
+	}
+	c.usage.finishedAt = time.Time{}
+	remaining := c.budget.MaxDuration - time.Since(c.usage.startedAt)
+	c.mux.Unlock()
+
+	var timer *time.Timer
+	if c.budget.MaxDuration > 0 {
+		timer = time.AfterFunc(remaining, func() {
+			c.mux.Lock()
+			defer c.mux.Unlock()
+			c.stopBy(BudgetMaxDuration)
+		})
+	}
+	return func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		c.usage.finishedAt = time.Now()
+	}
+}
+
+// takePage counts URL as a requested page. It returns false if a budget
+// has been reached, ErrBudgetExceeded if the host of URL has reached
+// MaxPagesPerHost, and ErrTrapped if the host or the pattern of URL has
+// reached the limit of the trap rule. URL rejected by the budget is not
+// counted by the trap rule.
+func (c *Crawler) takePage(URL *url.URL) (bool, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.stopped {
+		return false, nil
+	}
+	host := strings.ToLower(URL.Host)
+	if c.budget.MaxPagesPerHost > 0 && c.usage.hostPages[host] >= c.budget.MaxPagesPerHost {
+		return false, fmt.Errorf("%v: %s (max pages per host %d)", ErrBudgetExceeded, URL, c.budget.MaxPagesPerHost)
+	}
+	if c.trapRule != nil {
+		if err := c.trapRule.count(c.trapCounts, URL); err != nil {
+			return false, err
+		}
+	}
+	c.usage.pages++
+	c.usage.hostPages[host]++
+	if c.budget.MaxPages > 0 && c.usage.pages >= c.budget.MaxPages {
+		c.stopBy(BudgetMaxPages)
+	}
+	return true, nil
+}
+
+// addBytes counts n bytes of a response body.
+func (c *Crawler) addBytes(n int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.usage.bytes += int64(n)
+	if c.budget.MaxBytes > 0 && c.usage.bytes >= c.budget.MaxBytes {
+		c.stopBy(BudgetMaxBytes)
+	}
+}
+
+// stopBy stops crawling by the budget. c.mux must be locked.
+func (c *Crawler) stopBy(budget string) {
+	if c.stopped {
+		return
+	}
+	c.stopped = true
+	c.usage.stoppedBy = budget
+}
@@ -0,0 +1,126 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newEndlessServer returns the server where /N links to /N+1 forever.
+// Each response takes delay.
+func newEndlessServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<a href="/%04d">next</a>`, n+1)
+	}))
+}
+
+func crawlWithBudget(ts *httptest.Server, b Budget) (*Crawler, []string, []error) {
+	c := NewCrawler(ts.URL, 1000)
+	c.SetParallelism(1)
+	c.SetBudget(b)
+	var mux sync.Mutex
+	visited := make([]string, 0)
+	errs := make([]error, 0)
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		visited = append(visited, cr.URL.String())
+	})
+	c.OnError(func(err error) {
+		mux.Lock()
+		defer mux.Unlock()
+		errs = append(errs, err)
+	})
+	c.Crawl()
+	return c, visited, errs
+}
+
+func TestCrawlBudgetMaxPages(t *testing.T) {
+	ts := newEndlessServer(t, 0)
+	defer ts.Close()
+	c, visited, errs := crawlWithBudget(ts, Budget{MaxPages: 3})
+	assert.Equal(t, []string{ts.URL, ts.URL + "/0001", ts.URL + "/0002"}, visited)
+	assert.Empty(t, errs)
+	stats := c.Stats()
+	assert.Equal(t, 3, stats.Pages)
+	assert.Equal(t, BudgetMaxPages, stats.StoppedBy)
+	assert.True(t, c.Stopped())
+}
+
+func TestCrawlBudgetMaxBytes(t *testing.T) {
+	ts := newEndlessServer(t, 0)
+	defer ts.Close()
+	size := int64(len(`<a href="/0001">next</a>`))
+	c, visited, _ := crawlWithBudget(ts, Budget{MaxBytes: 2*size + 1})
+	assert.Len(t, visited, 3)
+	stats := c.Stats()
+	assert.Equal(t, 3*size, stats.Bytes)
+	assert.Equal(t, BudgetMaxBytes, stats.StoppedBy)
+}
+
+func TestCrawlBudgetMaxDuration(t *testing.T) {
+	ts := newEndlessServer(t, 20*time.Millisecond)
+	defer ts.Close()
+	c, visited, _ := crawlWithBudget(ts, Budget{MaxDuration: 100 * time.Millisecond})
+	stats := c.Stats()
+	assert.Equal(t, BudgetMaxDuration, stats.StoppedBy)
+	assert.True(t, len(visited) > 0 && len(visited) < 10, visited)
+	assert.True(t, stats.Duration >= 100*time.Millisecond, stats.Duration)
+}
+
+func TestCrawlBudgetMaxPagesPerHost(t *testing.T) {
+	ts := newEndlessServer(t, 0)
+	defer ts.Close()
+	c, visited, errs := crawlWithBudget(ts, Budget{MaxPagesPerHost: 2})
+	assert.Equal(t, []string{ts.URL, ts.URL + "/0001"}, visited)
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], fmt.Sprintf("%v: %s/0002 (max pages per host 2)", ErrBudgetExceeded, ts.URL))
+	}
+	stats := c.Stats()
+	assert.Equal(t, "", stats.StoppedBy)
+	assert.Equal(t, []string{strings.TrimPrefix(ts.URL, "http://")}, stats.HostsOverBudget)
+	assert.False(t, c.Stopped())
+}
+
+func TestTakePageTrapRule(t *testing.T) {
+	c := NewCrawler("https://test.com/", 1)
+	c.SetBudget(Budget{MaxPagesPerHost: 1})
+	c.SetTrapRule(&TrapRule{MaxURLsPerPattern: 1})
+	take := func(rawURL string) error {
+		URL, _ := url.Parse(rawURL)
+		_, err := c.takePage(URL)
+		return err
+	}
+
+	assert.NoError(t, take("https://test.com/a/1"))
+	err := take("https://test.com/b/1")
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), ErrBudgetExceeded.Error()))
+	}
+	// URLs rejected by the budget are not counted by the trap rule.
+	assert.Equal(t, map[string]int{"test.com/a/0": 1}, c.trapCounts.patterns)
+	assert.Equal(t, map[string]int{"test.com": 1}, c.trapCounts.hosts)
+}
+
+func TestCrawlWithoutBudget(t *testing.T) {
+	ts := newTestServer(t, "testdata/crawl.html")
+	defer ts.Close()
+	c := NewCrawler(ts.URL, 1)
+	c.Crawl()
+	stats := c.Stats()
+	assert.Equal(t, 1, stats.Pages)
+	assert.Equal(t, "", stats.StoppedBy)
+	assert.Empty(t, stats.HostsOverBudget)
+}
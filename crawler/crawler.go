@@ -2,13 +2,17 @@ package crawler
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/html"
-	"golang.org/x/net/html/atom"
 
 	"github.com/greytabby/grawl/fetcher"
 	"github.com/greytabby/grawl/scrape"
@@ -19,20 +23,97 @@ type (
 		baseRawURL       string
 		maxDepth         int
 		fetcher          Fetcher
+		extractor        Extractor
+		extractMetadata  bool
 		limitRule        *LimitRule
+		trapRule         *TrapRule
+		trapCounts       *trapCounts
+		budget           Budget
+		usage            budgetState
 		parallelism      chan struct{}
 		visitCallbacks   []VisitCallback
 		visitedCallbacks []VisitedCallback
 		errorCallbacks   []ErrorCallback
 		set              map[string]bool
+		hashes           map[string]*url.URL
+		canonicals       map[string]*url.URL
+		contentDedup     bool
+		canonicalDedup   bool
+		ignoreRobotsMeta bool
+		skipDupLinks     bool
+		followKinds      map[LinkKind]bool
+		linkExtractors   map[string]LinkExtractor
+		skipNofollow     bool
+		submitForms      bool
+		allowPostForms   bool
+		formValues       url.Values
+		stopped          bool
 		mux              sync.RWMutex
 		wg               sync.WaitGroup
 	}
 
 	CrawlResult struct {
-		URL   *url.URL
-		Body  string
-		Links []string
+		URL *url.URL
+		// Body is the response body as fetched. It must not be modified.
+		Body []byte
+		// Links is the links in Body with their kinds, in document order.
+		Links []Link
+		// ContentType is the media type of Body.
+		ContentType string
+		// FinalURL is the URL after following redirects.
+		FinalURL *url.URL
+		// Depth is the number of links followed from the site to URL.
+		Depth int
+		// Parent is the URL of the page that links to URL.
+		// It is nil for the site.
+		Parent     *url.URL
+		StatusCode int
+		Header     http.Header
+		FetchedAt  time.Time
+		// Hash is the hex encoded SHA-256 of Body.
+		Hash string
+		// DuplicateOf is the URL of the page visited first with the same
+		// Hash, or the same Canonical by canonical dedup. It is set only
+		// when content dedup or canonical dedup is enabled.
+		DuplicateOf *url.URL
+		// NoIndex and NoFollow are the robots directives of the page by
+		// `<meta name="robots">` and X-Robots-Tag header.
+		NoIndex  bool
+		NoFollow bool
+		// Canonical is the URL of `<link rel="canonical">` resolved
+		// against the base URL of the page. It is nil if the page does
+		// not have it.
+		Canonical *url.URL
+		// Data is the record extracted by the Extractor.
+		// It is nil if no Extractor is set, no rule matches URL or the
+		// page is not HTML.
+		Data map[string]interface{}
+		// Metadata is the structured metadata embedded in the page.
+		// It is nil unless metadata extraction is enabled and the page is HTML.
+		Metadata *scrape.Metadata
+		// Method is the method of the request, "GET" or "POST".
+		Method string
+		// FormData is the values posted by form submission.
+		// It is nil for GET requests.
+		FormData url.Values
+		// Forms is the forms in the page.
+		// It is nil unless form submission is enabled and the page is HTML.
+		Forms []*scrape.Form
+
+		// parseOnce guards root and parseErr, the DOM of Body parsed by DOM.
+		parseOnce sync.Once
+		root      *html.Node
+		parseErr  error
+	}
+
+	// request is a page to crawl.
+	request struct {
+		rawURL string
+		method string
+		// form is the body of POST request.
+		form   url.Values
+		depth  int
+		parent *url.URL
 	}
 )
 
@@ -49,6 +130,9 @@ var (
 	ErrForbidden = errors.New("Forbidden")
 	// ErrAlreadyVisitedDomain is the error for already visited URL
 	ErrAlreadyVisited = errors.New("Already visited")
+	// ErrPostNotSupported is the error thrown if the fetcher can not
+	// send POST request for form submission.
+	ErrPostNotSupported = errors.New("POST is not supported by fetcher")
 )
 
 type (
@@ -66,9 +150,29 @@ type Fetcher interface {
 	Fetch(URL string) (body []byte, err error)
 }
 
+// ResponseFetcher is a Fetcher that also returns response metadata
+// such as status code and headers.
+type ResponseFetcher interface {
+	Fetcher
+	FetchResponse(URL string) (*fetcher.Response, error)
+}
+
+// Extractor extracts a structured record from a parsed page.
+// It returns false if the page is not a target of extraction.
+type Extractor interface {
+	Extract(URL *url.URL, root *html.Node) (map[string]interface{}, bool)
+}
+
+// PostFetcher is a ResponseFetcher that also sends POST request.
+// It is required to submit POST forms.
+type PostFetcher interface {
+	ResponseFetcher
+	PostResponse(URL, contentType string, body []byte) (*fetcher.Response, error)
+}
+
 // NewCrawler returns `*Crawler`.
 func NewCrawler(URL string, maxDepth int) *Crawler {
-	return &Crawler{
+	c := &Crawler{
 		baseRawURL:       URL,
 		maxDepth:         maxDepth,
 		fetcher:          new(fetcher.DefaultFetcher),
@@ -76,7 +180,14 @@ func NewCrawler(URL string, maxDepth int) *Crawler {
 		parallelism:      make(chan struct{}, defaultParallelism),
 		visitedCallbacks: []VisitedCallback{},
 		set:              map[string]bool{},
+		hashes:           map[string]*url.URL{},
+		canonicals:       map[string]*url.URL{},
+		trapCounts:       newTrapCounts(),
+		usage:            budgetState{hostPages: map[string]int{}},
+		linkExtractors:   defaultLinkExtractors(),
 	}
+	c.SetFollowKinds(defaultFollowKinds...)
+	return c
 }
 
 // NewCrawlerWithLimitRule returns `*Crawler` with LimitRule.
@@ -92,12 +203,117 @@ func (c *Crawler) UseHeadlessChrome() {
 	c.fetcher = new(fetcher.HeadlessChrome)
 }
 
+// SetFetcher sets the Fetcher used at the time of request.
+func (c *Crawler) SetFetcher(f Fetcher) {
+	c.fetcher = f
+}
+
 // SetParallelism set limit of crawling parallelism.
 // By default, parallelism is 5.
 func (c *Crawler) SetParallelism(n int) {
 	c.parallelism = make(chan struct{}, n)
 }
 
+// SetTrapRule sets the TrapRule to detect crawler traps. URLs found in
+// traps are not visited and reported to OnError with ErrTrapped.
+// By default, traps are not detected.
+func (c *Crawler) SetTrapRule(r *TrapRule) {
+	c.trapRule = r
+}
+
+// SetExtractor sets the Extractor which fills Data of CrawlResult.
+func (c *Crawler) SetExtractor(e Extractor) {
+	c.extractor = e
+}
+
+// SetExtractMetadata enables extraction of JSON-LD, Microdata, RDFa,
+// OpenGraph and Twitter card metadata to Metadata of CrawlResult.
+// By default, metadata extraction is disabled.
+func (c *Crawler) SetExtractMetadata(enabled bool) {
+	c.extractMetadata = enabled
+}
+
+// SetContentDedup enables deduplication of pages by hash of the body.
+// A page that has the same body as a visited page is reported to OnVisited
+// with DuplicateOf set to the URL of the visited page.
+// By default, content dedup is disabled.
+func (c *Crawler) SetContentDedup(enabled bool) {
+	c.contentDedup = enabled
+}
+
+// SetCanonicalDedup enables deduplication of pages by their canonical URL.
+// A page that has the same canonical URL as a visited page is reported to
+// OnVisited with DuplicateOf set to the URL of the visited page.
+// By default, canonical dedup is disabled.
+func (c *Crawler) SetCanonicalDedup(enabled bool) {
+	c.canonicalDedup = enabled
+}
+
+// SetIgnoreRobotsMeta makes crawler follow links of pages with nofollow
+// directive of `<meta name="robots">` or X-Robots-Tag header.
+// By default, links of those pages are not followed.
+func (c *Crawler) SetIgnoreRobotsMeta(ignore bool) {
+	c.ignoreRobotsMeta = ignore
+}
+
+// SetSkipDuplicateLinks makes crawler not follow links of duplicate pages
+// found by content dedup. By default, links of duplicate pages are followed.
+func (c *Crawler) SetSkipDuplicateLinks(skip bool) {
+	c.skipDupLinks = skip
+}
+
+// SetFollowKinds sets the kinds of links followed by crawler.
+// By default, navigation, frame and redirect links are followed.
+// LinkHint is never followed.
+func (c *Crawler) SetFollowKinds(kinds ...LinkKind) {
+	c.followKinds = map[LinkKind]bool{}
+	for _, k := range kinds {
+		if k != LinkHint {
+			c.followKinds[k] = true
+		}
+	}
+}
+
+// SetLinkExtractor sets the LinkExtractor of the media type such as
+// "application/json". Nil e removes the extractor, and links are not
+// extracted from the type. By default, links are extracted from HTML,
+// CSS, XML, RSS, Atom, plain text, JSON and PDF.
+func (c *Crawler) SetLinkExtractor(mediaType string, e LinkExtractor) {
+	mediaType = strings.ToLower(mediaType)
+	if e == nil {
+		delete(c.linkExtractors, mediaType)
+		return
+	}
+	c.linkExtractors[mediaType] = e
+}
+
+// SetSkipNofollow makes crawler not follow links with rel="nofollow".
+// By default, nofollow links are followed.
+func (c *Crawler) SetSkipNofollow(skip bool) {
+	c.skipNofollow = skip
+}
+
+// SetSubmitForms enables submission of GET forms found in pages.
+// Forms are submitted with their default values overridden by SetFormValues,
+// and the result pages are crawled as links of the page.
+// By default, forms are not submitted.
+func (c *Crawler) SetSubmitForms(enabled bool) {
+	c.submitForms = enabled
+}
+
+// SetAllowPostForms allows submission of POST forms when form submission
+// is enabled. The fetcher must implement PostFetcher.
+// Multipart forms are never submitted.
+func (c *Crawler) SetAllowPostForms(allowed bool) {
+	c.allowPostForms = allowed
+}
+
+// SetFormValues sets the values used to submit forms instead of the default
+// values of the fields with the same name. Fields that forms do not have are ignored.
+func (c *Crawler) SetFormValues(values url.Values) {
+	c.formValues = values
+}
+
 // OnVisit register a function. Function will be executed on visiting web site.
 func (c *Crawler) OnVisit(f VisitCallback) {
 	c.visitCallbacks = append(c.visitCallbacks, f)
@@ -116,45 +332,160 @@ func (c *Crawler) OnError(f ErrorCallback) {
 
 // Crawl start crawling
 func (c *Crawler) Crawl() {
-	c.wg.Add(1)
-	go c.crawl(c.baseRawURL, 1)
+	c.CrawlURLs(c.baseRawURL)
+}
+
+// CrawlURLs starts crawling from rawURLs instead of the site.
+// Each URL is visited at depth 1. It returns when crawling is finished.
+func (c *Crawler) CrawlURLs(rawURLs ...string) {
+	defer c.startBudget()()
+	for _, rawURL := range rawURLs {
+		c.wg.Add(1)
+		go c.crawl(&request{rawURL: rawURL, depth: 1})
+	}
 	c.wg.Wait()
 }
 
-func (c *Crawler) crawl(rawURL string, depth int) {
+// Stop stops crawling. Pages in flight are finished, and no more pages
+// are visited. Crawl returns after that.
+func (c *Crawler) Stop() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.stopped = true
+}
+
+// Stopped reports whether Stop is called.
+func (c *Crawler) Stopped() bool {
+	return c.isStopped()
+}
+
+func (c *Crawler) isStopped() bool {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.stopped
+}
+
+func (c *Crawler) crawl(req *request) {
 	defer c.wg.Done()
 	c.parallelism <- struct{}{}
 	defer func() {
 		<-c.parallelism
 	}()
-	if depth > c.maxDepth {
+	if req.depth > c.maxDepth || c.isStopped() {
 		return
 	}
 
-	URL, err := url.Parse(rawURL)
+	URL, err := url.Parse(req.rawURL)
 	if err != nil {
 		c.handleErrorCallback(err)
 		return
 	}
-	if err = c.canVisit(URL); err != nil {
+	if err = c.canVisit(URL, req.key(URL)); err != nil {
 		c.handleErrorCallback(err)
 		return
 	}
+	if ok, err := c.takePage(URL); !ok {
+		if err != nil {
+			c.handleErrorCallback(err)
+		}
+		return
+	}
 
-	cr, err := c.visit(URL)
+	cr, err := c.visit(req, URL)
 	if err != nil {
 		c.handleErrorCallback(err)
 		return
 	}
 
+	if c.isStopped() {
+		return
+	}
+	if cr.DuplicateOf != nil && c.skipDupLinks {
+		return
+	}
+	if cr.NoFollow && !c.ignoreRobotsMeta {
+		return
+	}
+	next := make([]*request, 0, len(cr.Links))
 	for _, link := range cr.Links {
-		nextRawURL := fixURL(URL, link)
+		if !c.followKinds[link.Kind] || c.skipNofollow && link.HasRel("nofollow") {
+			continue
+		}
+		next = append(next, &request{rawURL: link.AbsURL, depth: req.depth + 1, parent: URL})
+	}
+	for _, f := range cr.Forms {
+		if r := c.formRequest(cr.BaseURL(), f); r != nil {
+			r.depth, r.parent = req.depth+1, URL
+			next = append(next, r)
+		}
+	}
+	for _, r := range next {
 		c.wg.Add(1)
-		go c.crawl(nextRawURL, depth+1)
+		go c.crawl(r)
+	}
+}
+
+// key returns the key of the request in the set of visited pages.
+func (req *request) key(URL *url.URL) string {
+	if req.method != http.MethodPost {
+		return URL.String()
 	}
+	return http.MethodPost + " " + URL.String() + " " + req.form.Encode()
 }
 
-func (c *Crawler) canVisit(URL *url.URL) error {
+// formRequest returns the request that submits the form in the page at base,
+// or nil if the form is not submitted.
+func (c *Crawler) formRequest(base *url.URL, f *scrape.Form) *request {
+	action, err := base.Parse(f.Action)
+	if err != nil {
+		return nil
+	}
+	values := f.Values()
+	for name, v := range c.formValues {
+		if f.Field(name) != nil {
+			values[name] = v
+		}
+	}
+
+	switch f.Method {
+	case http.MethodGet:
+		action.RawQuery = values.Encode()
+		action.Fragment = ""
+		return &request{rawURL: action.String()}
+	case http.MethodPost:
+		if !c.allowPostForms || f.Enctype == scrape.EnctypeMultipart {
+			return nil
+		}
+		action.Fragment = ""
+		return &request{rawURL: action.String(), method: http.MethodPost, form: values}
+	}
+	return nil
+}
+
+func (c *Crawler) canVisit(URL *url.URL, key string) error {
+	if err := c.checkURL(URL); err != nil {
+		return err
+	}
+
+	if c.hasVisited(key) {
+		return fmt.Errorf("%v: %s", ErrAlreadyVisited, URL)
+	}
+
+	return nil
+}
+
+// CheckURL returns the error if rawURL is never crawled, because it is
+// invalid, or denied by the limit rule or the trap rule. Limits counted
+// while crawling, such as the budget and URLs per host, are not checked.
+func (c *Crawler) CheckURL(rawURL string) error {
+	URL, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	return c.checkURL(URL)
+}
+
+func (c *Crawler) checkURL(URL *url.URL) error {
 	if !isValidURL(URL) {
 		return fmt.Errorf("%v: %s", ErrInvalidURL, URL)
 	}
@@ -163,10 +494,9 @@ func (c *Crawler) canVisit(URL *url.URL) error {
 		return fmt.Errorf("%v: %s", ErrForbidden, URL.String())
 	}
 
-	if c.hasVisited(URL.String()) {
-		return fmt.Errorf("%v: %s", ErrAlreadyVisited, URL)
+	if c.trapRule != nil {
+		return c.trapRule.check(URL)
 	}
-
 	return nil
 }
 
@@ -195,34 +525,150 @@ func (c *Crawler) setVisit(URL string) {
 	c.set[URL] = true
 }
 
-func (c *Crawler) visit(URL *url.URL) (*CrawlResult, error) {
-	c.setVisit(URL.String())
-	body, err := c.fetcher.Fetch(URL.String())
+func (c *Crawler) visit(req *request, URL *url.URL) (*CrawlResult, error) {
+	c.setVisit(req.key(URL))
+	fetchedAt := time.Now()
+	resp, err := c.fetch(req, URL)
 	if err != nil {
 		return nil, err
 	}
+	body := resp.Body
+	c.addBytes(len(body))
 	c.handleVisitCallback(body)
 
-	links, err := extractLinks(body)
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	cr := &CrawlResult{
+		URL:         URL,
+		Body:        body,
+		ContentType: contentType,
+		FinalURL:    resp.URL,
+		Depth:       req.depth,
+		Parent:      req.parent,
+		StatusCode:  resp.StatusCode,
+		Header:      resp.Header,
+		FetchedAt:   fetchedAt,
+		Hash:        hash,
+		Method:      http.MethodGet,
+	}
+	if req.method == http.MethodPost {
+		cr.Method, cr.FormData = http.MethodPost, req.form
+	}
+	h := &head{}
+	if IsHTML(contentType) {
+		h = scanHead(body)
+	}
+	cr.NoIndex, cr.NoFollow = robotsDirectives(h, resp.Header)
+	if h.canonical != "" {
+		cr.Canonical, _ = documentBase(cr.BaseURL(), h.base).Parse(h.canonical)
+	}
+	if c.canonicalDedup && cr.Canonical != nil {
+		cr.DuplicateOf = c.setFirst(c.canonicals, cr.Canonical.String(), URL)
+	}
+	if c.contentDedup && cr.DuplicateOf == nil {
+		cr.DuplicateOf = c.setFirst(c.hashes, hash, URL)
+	}
+	cr.Links = c.extractLinks(cr)
+	if !c.needsDOM() || !IsHTML(cr.ContentType) {
+		c.handleVisitedCallback(cr)
+		return cr, nil
+	}
+
+	root, err := cr.DOM()
 	if err != nil {
 		return nil, err
 	}
-	cr := &CrawlResult{URL, string(body), links}
+	if c.extractMetadata {
+		cr.Metadata = scrape.ExtractMetadata(root)
+	}
+	if c.extractor != nil {
+		if data, ok := c.extractor.Extract(URL, root); ok {
+			cr.Data = data
+		}
+	}
+	if c.submitForms {
+		cr.Forms = scrape.Forms(root)
+	}
 	c.handleVisitedCallback(cr)
 	return cr, nil
 }
 
-func extractLinks(body []byte) (links []string, err error) {
-	rootNode, err := html.Parse(bytes.NewReader(body))
+// extractLinks returns links of the page by the LinkExtractor of its
+// content type. Errors of the extractor are reported to OnError,
+// and the page has no links.
+func (c *Crawler) extractLinks(cr *CrawlResult) []Link {
+	e := c.linkExtractor(cr.ContentType)
+	if e == nil {
+		return []Link{}
+	}
+	links, err := e.ExtractLinks(cr.Body, cr.BaseURL())
 	if err != nil {
-		return nil, err
+		c.handleErrorCallback(fmt.Errorf("%v: %s: %v", ErrLinkExtraction, cr.URL, err))
+		return []Link{}
+	}
+	resolveLinks(links, cr.BaseURL())
+	return links
+}
+
+// BaseURL returns the URL which relative links of the page are resolved
+// against, FinalURL or URL if FinalURL is nil.
+func (cr *CrawlResult) BaseURL() *url.URL {
+	if cr.FinalURL != nil {
+		return cr.FinalURL
+	}
+	return cr.URL
+}
+
+// needsDOM reports whether the DOM of every page is used by the crawler.
+// Links are always extracted by the tokenizer without building the DOM.
+func (c *Crawler) needsDOM() bool {
+	return c.extractMetadata || c.extractor != nil || c.submitForms
+}
+
+// DOM returns the parsed Body. Body is parsed at most once, when DOM is
+// called first, and the same tree is returned to every caller.
+// The tree must not be modified.
+func (cr *CrawlResult) DOM() (*html.Node, error) {
+	cr.parseOnce.Do(func() {
+		cr.root, cr.parseErr = html.Parse(bytes.NewReader(cr.Body))
+	})
+	return cr.root, cr.parseErr
+}
+
+// setFirst records URL as the first page with key in m, and returns nil.
+// If key is already recorded, it returns the first URL.
+func (c *Crawler) setFirst(m map[string]*url.URL, key string, URL *url.URL) *url.URL {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if first, ok := m[key]; ok {
+		return first
+	}
+	m[key] = URL
+	return nil
+}
+
+// fetch fetches URL with the fetcher. When the fetcher does not implement
+// ResponseFetcher, the response has only body.
+func (c *Crawler) fetch(req *request, URL *url.URL) (*fetcher.Response, error) {
+	if req.method == http.MethodPost {
+		pf, ok := c.fetcher.(PostFetcher)
+		if !ok {
+			return nil, fmt.Errorf("%v: %s", ErrPostNotSupported, URL)
+		}
+		return pf.PostResponse(URL.String(), scrape.EnctypeURLEncoded, []byte(req.form.Encode()))
 	}
-	anchorNodes := scrape.FindAll(rootNode, scrape.ByTag(atom.A))
-	links = make([]string, len(anchorNodes))
-	for i, v := range anchorNodes {
-		links[i] = scrape.Attr(v, "href")
+	if rf, ok := c.fetcher.(ResponseFetcher); ok {
+		return rf.FetchResponse(URL.String())
 	}
-	return links, nil
+	body, err := c.fetcher.Fetch(URL.String())
+	if err != nil {
+		return nil, err
+	}
+	return &fetcher.Response{URL: URL, Header: http.Header{}, Body: body}, nil
 }
 
 func (c *Crawler) handleVisitCallback(response []byte) {
@@ -242,23 +688,3 @@ func (c *Crawler) handleErrorCallback(err error) {
 		f(err)
 	}
 }
-
-func fixURL(currentURL *url.URL, nextURL string) string {
-	u, err := url.Parse(nextURL)
-
-	if err != nil {
-		return ""
-	}
-
-	if u.IsAbs() {
-		return u.String()
-	}
-
-	if u.Host == "" && u.Path != "" {
-		u.Host = currentURL.Host
-		u.Scheme = currentURL.Scheme
-		return u.String()
-	}
-
-	return ""
-}
@@ -7,9 +7,16 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/greytabby/grawl/fetcher"
+	"github.com/greytabby/grawl/scrape"
 )
 
 func newTestServer(t *testing.T, testfile string) *httptest.Server {
@@ -62,7 +69,7 @@ func TestCrawl(t *testing.T) {
 		baseURL,
 		baseURL + "/image/test1.png",
 		baseURL + "/image/test2.jpg",
-		baseURL + "/image/../relative/test3",
+		baseURL + "/relative/test3",
 	}
 
 	if !confirmCrawlResult(t, want, got) {
@@ -148,3 +155,376 @@ func TestCrawlOnError(t *testing.T) {
 
 	// TODO: Test html parse error
 }
+
+func TestCrawlResultMetadata(t *testing.T) {
+	ts := newTestServer(t, "testdata/crawl-with-host-limit.html")
+	defer ts.Close()
+	URL, _ := url.Parse(ts.URL)
+	limitRule := NewLimitRule()
+	limitRule.AddAllowedHosts(URL.Host)
+	c := NewCrawlerWithLimitRule(ts.URL, 2, limitRule)
+	var mux sync.Mutex
+	got := map[string]*CrawlResult{}
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		got[cr.URL.String()] = cr
+	})
+	c.Crawl()
+
+	root := got[ts.URL]
+	if assert.NotNil(t, root) {
+		assert.Equal(t, 1, root.Depth)
+		assert.Nil(t, root.Parent)
+		assert.Equal(t, http.StatusOK, root.StatusCode)
+		assert.Equal(t, "text/html", root.ContentType)
+		assert.Equal(t, ts.URL, root.FinalURL.String())
+		assert.False(t, root.FetchedAt.IsZero())
+	}
+
+	child := got[ts.URL+"/image/test2.jpg"]
+	if assert.NotNil(t, child) {
+		assert.Equal(t, 2, child.Depth)
+		assert.Equal(t, ts.URL, child.Parent.String())
+	}
+}
+
+func TestCrawlStop(t *testing.T) {
+	ts := newTestServer(t, "testdata/crawl.html")
+	defer ts.Close()
+	c := NewCrawler(ts.URL, 3)
+	got := make([]string, 0)
+	c.OnVisited(func(cr *CrawlResult) {
+		got = append(got, cr.URL.String())
+		c.Stop()
+	})
+	c.Crawl()
+
+	assert.Equal(t, []string{ts.URL}, got)
+}
+
+func TestCrawlContentDedup(t *testing.T) {
+	ts := newTestServer(t, "testdata/crawl-dont-visit-same-url.html")
+	defer ts.Close()
+	c := NewCrawler(ts.URL, 2)
+	c.SetContentDedup(true)
+	var mux sync.Mutex
+	originals := make([]string, 0)
+	duplicates := make([]*CrawlResult, 0)
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		if cr.DuplicateOf == nil {
+			originals = append(originals, cr.URL.String())
+			return
+		}
+		duplicates = append(duplicates, cr)
+	})
+	c.Crawl()
+
+	// Every page but the site serves the same body.
+	assert.Equal(t, 2, len(originals))
+	assert.NotEmpty(t, duplicates)
+	for _, cr := range duplicates {
+		assert.Contains(t, originals, cr.DuplicateOf.String())
+	}
+}
+
+func TestCrawlSkipDuplicateLinks(t *testing.T) {
+	ts := newTestServer(t, "testdata/crawl-dont-visit-same-url.html")
+	defer ts.Close()
+	c := NewCrawler(ts.URL, 3)
+	c.SetContentDedup(true)
+	c.SetSkipDuplicateLinks(true)
+	var mux sync.Mutex
+	duplicates := map[string]bool{}
+	parents := make([]string, 0)
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		if cr.DuplicateOf != nil {
+			duplicates[cr.URL.String()] = true
+		}
+		if cr.Parent != nil {
+			parents = append(parents, cr.Parent.String())
+		}
+	})
+	c.Crawl()
+
+	assert.NotEmpty(t, duplicates)
+	for _, p := range parents {
+		assert.False(t, duplicates[p], "links of duplicate page %s are followed", p)
+	}
+}
+
+type titleExtractor struct{}
+
+func (titleExtractor) Extract(URL *url.URL, root *html.Node) (map[string]interface{}, bool) {
+	if URL.Path != "" {
+		return nil, false
+	}
+	title := scrape.Find(root, scrape.ByTag(atom.Title))
+	if title == nil {
+		return nil, false
+	}
+	return map[string]interface{}{"title": scrape.Text(title)}, true
+}
+
+func TestCrawlExtractor(t *testing.T) {
+	ts := newTestServer(t, "testdata/crawl.html")
+	defer ts.Close()
+	c := NewCrawler(ts.URL, 2)
+	c.SetExtractor(titleExtractor{})
+	var mux sync.Mutex
+	got := map[string]*CrawlResult{}
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		got[cr.URL.String()] = cr
+	})
+	c.Crawl()
+
+	if assert.NotNil(t, got[ts.URL]) {
+		assert.Equal(t, map[string]interface{}{"title": "test file"}, got[ts.URL].Data)
+	}
+	if assert.NotNil(t, got[ts.URL+"/image/test2.jpg"]) {
+		assert.Nil(t, got[ts.URL+"/image/test2.jpg"].Data)
+	}
+}
+
+type pathExtractor struct{}
+
+func (pathExtractor) Extract(URL *url.URL, root *html.Node) (map[string]interface{}, bool) {
+	return map[string]interface{}{"path": URL.Path}, true
+}
+
+func TestCrawlDOMOnlyHTML(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/data.json" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"form": "<form action=/submit></form>"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="/data.json">data</a><form action="/search"></form>`)
+	}))
+	defer ts.Close()
+
+	c := NewCrawler(ts.URL, 2)
+	c.SetExtractor(pathExtractor{})
+	c.SetExtractMetadata(true)
+	c.SetSubmitForms(true)
+	var mux sync.Mutex
+	got := map[string]*CrawlResult{}
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		got[cr.URL.Path] = cr
+	})
+	c.Crawl()
+
+	if cr := got[""]; assert.NotNil(t, cr) {
+		assert.Equal(t, map[string]interface{}{"path": ""}, cr.Data)
+		assert.NotNil(t, cr.Metadata)
+		assert.Len(t, cr.Forms, 1)
+	}
+	if cr := got["/data.json"]; assert.NotNil(t, cr) {
+		assert.Nil(t, cr.Data)
+		assert.Nil(t, cr.Metadata)
+		assert.Nil(t, cr.Forms)
+	}
+}
+
+func TestCrawlExtractMetadata(t *testing.T) {
+	ts := newTestServer(t, "testdata/crawl-metadata.html")
+	defer ts.Close()
+	c := NewCrawler(ts.URL, 1)
+	c.SetExtractMetadata(true)
+	var got *CrawlResult
+	c.OnVisited(func(cr *CrawlResult) {
+		got = cr
+	})
+	c.Crawl()
+
+	if assert.NotNil(t, got) && assert.NotNil(t, got.Metadata) {
+		assert.Equal(t, []string{"test file"}, got.Metadata.OpenGraph["title"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"@type": "WebPage", "name": "test file"}}, got.Metadata.JSONLD)
+		assert.Len(t, got.Metadata.Warnings, 1)
+	}
+}
+
+func newFormTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	content, err := ioutil.ReadFile("testdata/crawl-forms.html")
+	assert.NoError(t, err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			w.Write(content)
+			return
+		}
+		r.ParseForm()
+		fmt.Fprintf(w, "<p>%s %s</p>", r.Method, r.Form.Encode())
+	})
+	return httptest.NewServer(handler)
+}
+
+func TestCrawlSubmitForms(t *testing.T) {
+	ts := newFormTestServer(t)
+	defer ts.Close()
+
+	crawl := func(allowPost bool) map[string]*CrawlResult {
+		c := NewCrawler(ts.URL, 2)
+		c.SetSubmitForms(true)
+		c.SetAllowPostForms(allowPost)
+		c.SetFormValues(url.Values{"q": {"test"}, "unknown": {"x"}})
+		var mux sync.Mutex
+		got := map[string]*CrawlResult{}
+		c.OnVisited(func(cr *CrawlResult) {
+			mux.Lock()
+			defer mux.Unlock()
+			got[cr.Method+" "+cr.URL.String()] = cr
+		})
+		c.Crawl()
+		return got
+	}
+
+	got := crawl(false)
+	assert.Len(t, got, 2)
+	if root := got["GET "+ts.URL]; assert.NotNil(t, root) {
+		assert.Len(t, root.Forms, 3)
+	}
+	if cr := got["GET "+ts.URL+"/search?lang=en&q=test"]; assert.NotNil(t, cr) {
+		assert.Nil(t, cr.FormData)
+		assert.Equal(t, "<p>GET lang=en&q=test</p>", string(cr.Body))
+	}
+
+	got = crawl(true)
+	assert.Len(t, got, 3)
+	if cr := got["POST "+ts.URL+"/login"]; assert.NotNil(t, cr) {
+		assert.Equal(t, url.Values{"user": {"guest"}, "password": {""}}, cr.FormData)
+		assert.Equal(t, "<p>POST password=&user=guest</p>", string(cr.Body))
+		assert.Equal(t, ts.URL, cr.Parent.String())
+	}
+}
+
+// getFetcher is a Fetcher which does not send POST request.
+type getFetcher struct{}
+
+func (getFetcher) Fetch(URL string) ([]byte, error) {
+	return new(fetcher.DefaultFetcher).Fetch(URL)
+}
+
+func TestCrawlSubmitFormsPostNotSupported(t *testing.T) {
+	ts := newFormTestServer(t)
+	defer ts.Close()
+	c := NewCrawler(ts.URL, 2)
+	c.fetcher = getFetcher{}
+	c.SetSubmitForms(true)
+	c.SetAllowPostForms(true)
+	var mux sync.Mutex
+	var errs []error
+	c.OnError(func(err error) {
+		mux.Lock()
+		defer mux.Unlock()
+		errs = append(errs, err)
+	})
+	c.Crawl()
+
+	if assert.Len(t, errs, 1) {
+		assert.True(t, strings.HasPrefix(errs[0].Error(), ErrPostNotSupported.Error()))
+	}
+}
+
+func TestCrawlResultDOM(t *testing.T) {
+	cr := &CrawlResult{Body: []byte("<title>test</title>")}
+	root, err := cr.DOM()
+	assert.NoError(t, err)
+	assert.Equal(t, "test", scrape.Text(scrape.Find(root, scrape.ByTag(atom.Title))))
+	again, err := cr.DOM()
+	assert.NoError(t, err)
+	assert.True(t, root == again)
+}
+
+func TestCrawlURLs(t *testing.T) {
+	ts := newTestServer(t, "testdata/crawl.html")
+	defer ts.Close()
+	c := NewCrawler(ts.URL, 1)
+	var mux sync.Mutex
+	visited := make([]string, 0)
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		visited = append(visited, cr.URL.String())
+		assert.Equal(t, 1, cr.Depth)
+	})
+	c.CrawlURLs(ts.URL+"/a", ts.URL+"/b")
+	assert.ElementsMatch(t, []string{ts.URL + "/a", ts.URL + "/b"}, visited)
+	assert.False(t, c.Stopped())
+}
+
+func TestCrawlRedirectBaseURL(t *testing.T) {
+	pages := map[string]string{
+		"/":                `<a href="/docs">docs</a>`,
+		"/docs/":           `<link rel="canonical" href="index.html"><a href="intro.html">intro</a>`,
+		"/docs/intro.html": `<base href="/api/"><link rel="canonical" href="intro"><a href="ref.html">ref</a>`,
+		"/api/ref.html":    `ref`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/docs" {
+			http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+			return
+		}
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, body)
+	}))
+	defer ts.Close()
+
+	c := NewCrawler(ts.URL, 4)
+	var mux sync.Mutex
+	got := map[string]*CrawlResult{}
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		got[cr.URL.String()] = cr
+	})
+	c.Crawl()
+
+	visited := make([]string, 0)
+	for u := range got {
+		visited = append(visited, u)
+	}
+	// Canonical links are followed too.
+	assert.ElementsMatch(t, []string{
+		ts.URL, ts.URL + "/docs", ts.URL + "/docs/index.html", ts.URL + "/docs/intro.html",
+		ts.URL + "/api/intro", ts.URL + "/api/ref.html",
+	}, visited)
+	if cr := got[ts.URL+"/docs"]; assert.NotNil(t, cr) {
+		assert.Equal(t, ts.URL+"/docs/", cr.BaseURL().String())
+		assert.Equal(t, ts.URL+"/docs/index.html", cr.Canonical.String())
+		assert.Equal(t, ts.URL+"/docs/intro.html", cr.Links[1].AbsURL)
+	}
+	if cr := got[ts.URL+"/docs/intro.html"]; assert.NotNil(t, cr) {
+		assert.Equal(t, ts.URL+"/api/intro", cr.Canonical.String())
+	}
+}
+
+func TestCrawlerCheckURL(t *testing.T) {
+	lr := NewLimitRule()
+	lr.AddAllowedHosts("test.com")
+	c := NewCrawlerWithLimitRule("https://test.com/", 1, lr)
+	c.SetTrapRule(&TrapRule{MaxPathDepth: 2, MaxURLsPerHost: 1})
+
+	assert.NoError(t, c.CheckURL("https://test.com/a/b"))
+	// Limits counted while crawling are not checked.
+	assert.NoError(t, c.CheckURL("https://test.com/a/c"))
+	for _, u := range []string{"https://other.com/", "ftp://test.com/", "https://test.com/a/b/c", "http://[::1"} {
+		assert.Error(t, c.CheckURL(u), u)
+	}
+}
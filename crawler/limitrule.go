@@ -1,15 +1,37 @@
 package crawler
 
 import (
+	"fmt"
 	"net/url"
+	"path"
 	"regexp"
+	"strings"
 )
 
+// LimitRule decides URLs that crawler can visit.
+//
+// Host patterns of AllowedHosts and DisallowedHosts are an exact host such as
+// "example.com" or "example.com:8080", "*.example.com" for its subdomains,
+// or ".example.com" for the domain and its subdomains. Patterns match hosts
+// with the same port, or any port if the pattern ends with ":*" such as
+// "example.com:*".
 type LimitRule struct {
 	// AllowedHosts define accessible hosts.
 	// When AllowedHosts is empty, all hosts are allowed.
 	AllowedHosts []string
 	AllowedUrls  []*regexp.Regexp
+	// DisallowedHosts define inaccessible hosts.
+	// They take precedence over AllowedHosts.
+	DisallowedHosts []string
+	// DisallowedUrls define inaccessible URLs.
+	// They take precedence over AllowedUrls.
+	DisallowedUrls []*regexp.Regexp
+	// AllowedSchemes define accessible schemes such as "https".
+	// When AllowedSchemes is empty, all schemes are allowed.
+	AllowedSchemes []string
+	// PathPrefixes define URL prefixes such as "https://example.com/docs/".
+	// When PathPrefixes is not empty, only URLs under them are allowed.
+	PathPrefixes []string
 }
 
 // NewLimitRule returns empty LimitRule.
@@ -22,6 +44,16 @@ func NewLimitRule() *LimitRule {
 
 // IsAllow returns true if requestURL is no limit to crawl.
 func (lr *LimitRule) IsAllow(requestURL *url.URL) bool {
+	if !lr.isAllowedScheme(requestURL.Scheme) || lr.isDisallowedHost(requestURL.Host) ||
+		!lr.isUnderPathPrefix(requestURL) {
+		return false
+	}
+	for _, disallowedURL := range lr.DisallowedUrls {
+		if disallowedURL.MatchString(requestURL.String()) {
+			return false
+		}
+	}
+
 	if lr.isAllowedHost(requestURL.Host) {
 		if len(lr.AllowedUrls) == 0 {
 			return true
@@ -46,15 +78,124 @@ func (lr *LimitRule) AddAllowedUrls(re *regexp.Regexp) {
 	lr.AllowedUrls = append(lr.AllowedUrls, re)
 }
 
+// AddDisallowedHosts add rule define inaccessible hosts.
+func (lr *LimitRule) AddDisallowedHosts(hosts ...string) {
+	lr.DisallowedHosts = append(lr.DisallowedHosts, hosts...)
+}
+
+// AddDisallowedUrls add rule define inaccessible URLs.
+func (lr *LimitRule) AddDisallowedUrls(re *regexp.Regexp) {
+	lr.DisallowedUrls = append(lr.DisallowedUrls, re)
+}
+
+// AddAllowedSchemes add rule define accessible schemes.
+func (lr *LimitRule) AddAllowedSchemes(schemes ...string) {
+	lr.AllowedSchemes = append(lr.AllowedSchemes, schemes...)
+}
+
+// AddSeedPathPrefix restricts URLs to the directory of seed, such as
+// "https://example.com/docs/" for "https://example.com/docs/intro.html".
+func (lr *LimitRule) AddSeedPathPrefix(seed string) error {
+	u, err := url.Parse(seed)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%v: %s", ErrInvalidURL, seed)
+	}
+	dir := u.Path
+	if dir == "" {
+		dir = "/"
+	}
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+		if !strings.HasSuffix(dir, "/") {
+			dir += "/"
+		}
+	}
+	prefix := url.URL{Scheme: u.Scheme, Host: u.Host, Path: dir}
+	lr.PathPrefixes = append(lr.PathPrefixes, prefix.String())
+	return nil
+}
+
 func (lr *LimitRule) isAllowedHost(host string) bool {
 	if len(lr.AllowedHosts) == 0 {
 		return true
 	}
 
 	for _, h := range lr.AllowedHosts {
-		if h == host {
+		if matchHost(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (lr *LimitRule) isDisallowedHost(host string) bool {
+	for _, h := range lr.DisallowedHosts {
+		if matchHost(h, host) {
 			return true
 		}
 	}
 	return false
 }
+
+func (lr *LimitRule) isAllowedScheme(scheme string) bool {
+	if len(lr.AllowedSchemes) == 0 {
+		return true
+	}
+	for _, s := range lr.AllowedSchemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func (lr *LimitRule) isUnderPathPrefix(requestURL *url.URL) bool {
+	if len(lr.PathPrefixes) == 0 {
+		return true
+	}
+	u := *requestURL
+	u.Path, u.RawPath = cleanPath(u.Path), ""
+	for _, p := range lr.PathPrefixes {
+		if strings.HasPrefix(u.String(), p) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPath returns p without dot segments. The trailing slash is kept,
+// and the empty path is "/".
+func cleanPath(p string) string {
+	cleaned := path.Clean("/" + p)
+	if cleaned != "/" && (strings.HasSuffix(p, "/") || strings.HasSuffix(p, "/.") || strings.HasSuffix(p, "/..")) {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// matchHost reports whether host matches the host pattern.
+func matchHost(pattern, host string) bool {
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+	if pattern == "" || host == "" {
+		return pattern == host
+	}
+	if strings.HasSuffix(pattern, ":*") {
+		pattern = strings.TrimSuffix(pattern, ":*")
+		if u, err := url.Parse("//" + host); err == nil {
+			host = u.Hostname()
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	case strings.HasPrefix(pattern, "."):
+		return host == pattern[1:] || strings.HasSuffix(host, pattern)
+	}
+	return pattern == host
+}
@@ -2,6 +2,7 @@ package crawler
 
 import (
 	"net/url"
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -71,3 +72,102 @@ func TestIsAllow(t *testing.T) {
 		assert.Equal(t, tt.want, got)
 	}
 }
+
+func TestMatchHost(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{"example.com", "example.com", true},
+		{"example.com", "EXAMPLE.com", true},
+		{"example.com", "example.com:8080", false},
+		{"example.com:8080", "example.com:8080", true},
+		{"example.com:8080", "example.com:9090", false},
+		{"example.com:8080", "example.com", false},
+		{"example.com:*", "example.com", true},
+		{"example.com:*", "example.com:8443", true},
+		{"example.com:*", "www.example.com:8443", false},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "www.example.com:8080", false},
+		{"*.example.com:8080", "www.example.com:8080", true},
+		{"*.example.com:*", "a.b.example.com:8080", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+		{".example.com", "example.com", true},
+		{".example.com", "www.example.com", true},
+		{".example.com", "badexample.com", false},
+		{".example.com:*", "www.example.com:8443", true},
+		{"[::1]:*", "[::1]:8080", true},
+		{"example.com", "", false},
+	}
+
+	for _, tt := range testCases {
+		got := matchHost(tt.pattern, tt.host)
+		assert.Equal(t, tt.want, got, tt.pattern+" "+tt.host)
+	}
+}
+
+func TestIsAllowWithDisallowRules(t *testing.T) {
+	testCases := []struct {
+		rawURL string
+		want   bool
+	}{
+		{"https://example.com/", true},
+		{"https://www.example.com/docs", true},
+		{"http://www.example.com/docs", false},
+		{"https://ads.example.com/", false},
+		{"https://other.com/", false},
+		{"https://example.com/private/a", false},
+		{"https://example.com/docs?session=1", false},
+	}
+
+	limitRule := NewLimitRule()
+	limitRule.AddAllowedHosts(".example.com")
+	limitRule.AddDisallowedHosts("ads.example.com")
+	limitRule.AddDisallowedUrls(regexp.MustCompile(`/private/`))
+	limitRule.AddDisallowedUrls(regexp.MustCompile(`[?&]session=`))
+	limitRule.AddAllowedSchemes("https")
+	for _, tt := range testCases {
+		requestURL, err := url.Parse(tt.rawURL)
+		assert.NoError(t, err)
+		got := limitRule.IsAllow(requestURL)
+		assert.Equal(t, tt.want, got, tt.rawURL)
+	}
+}
+
+func TestAddSeedPathPrefix(t *testing.T) {
+	testCases := []struct {
+		seed   string
+		rawURL string
+		want   bool
+	}{
+		{"https://example.com/docs/intro.html", "https://example.com/docs/", true},
+		{"https://example.com/docs/intro.html", "https://example.com/docs/api/a.html", true},
+		{"https://example.com/docs/intro.html", "https://example.com/blog/", false},
+		{"https://example.com/docs/intro.html", "https://example.com/docs-old/", false},
+		{"https://example.com/docs/", "https://example.com/docs/intro.html", true},
+		{"https://example.com/docs/", "https://other.com/docs/intro.html", false},
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "https://example.com/a/b", true},
+		{"https://example.com/docs/", "https://example.com/docs/../admin/", false},
+		{"https://example.com/docs/", "https://example.com/docs/%2e%2e/admin/", false},
+		{"https://example.com/docs/", "https://example.com/docs/./a/../b", true},
+		{"https://example.com/docs/", "https://example.com/docs/a/..", true},
+		{"https://example.com/docs/", "https://example.com/docs/..", false},
+	}
+
+	for _, tt := range testCases {
+		limitRule := NewLimitRule()
+		assert.NoError(t, limitRule.AddSeedPathPrefix(tt.seed))
+		requestURL, err := url.Parse(tt.rawURL)
+		assert.NoError(t, err)
+		got := limitRule.IsAllow(requestURL)
+		assert.Equal(t, tt.want, got, tt.seed+" "+tt.rawURL)
+	}
+
+	limitRule := NewLimitRule()
+	assert.Error(t, limitRule.AddSeedPathPrefix("/docs/"))
+}
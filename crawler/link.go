@@ -0,0 +1,427 @@
+package crawler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// LinkKind is the kind of resource a link refers to.
+type LinkKind string
+
+// Kinds of links.
+const (
+	// LinkNavigation is a link to another page, such as `<a href>`.
+	LinkNavigation LinkKind = "navigation"
+	// LinkStylesheet is a stylesheet by `<link rel="stylesheet">` or `@import`.
+	LinkStylesheet LinkKind = "stylesheet"
+	// LinkImage is an image, including images and fonts by `url()` in CSS.
+	LinkImage LinkKind = "image"
+	// LinkScript is a script by `<script src>`.
+	LinkScript LinkKind = "script"
+	// LinkFrame is a page embedded by `<iframe>` or `<frame>`.
+	LinkFrame LinkKind = "frame"
+	// LinkRedirect is the destination of `<meta http-equiv="refresh">`.
+	LinkRedirect LinkKind = "redirect"
+	// LinkMedia is a video, audio or text track.
+	LinkMedia LinkKind = "media"
+	// LinkForm is the action of a form.
+	LinkForm LinkKind = "form"
+	// LinkOther is a resource of `<link>` which is not a page, such as
+	// manifest, pingback and search.
+	LinkOther LinkKind = "other"
+	// LinkHint is an origin of resource hints, preconnect and dns-prefetch.
+	// It is never followed.
+	LinkHint LinkKind = "hint"
+)
+
+// LinkKinds is every kind of links which can be followed.
+var LinkKinds = []LinkKind{
+	LinkNavigation, LinkStylesheet, LinkImage, LinkScript,
+	LinkFrame, LinkRedirect, LinkMedia, LinkForm, LinkOther,
+}
+
+// defaultFollowKinds is the kinds of links followed by default.
+var defaultFollowKinds = []LinkKind{LinkNavigation, LinkFrame, LinkRedirect}
+
+// ErrUnknownLinkKind is the error for an invalid kind of links.
+var ErrUnknownLinkKind = errors.New("Unknown link kind")
+
+// Link is a reference found in a page.
+type Link struct {
+	// URL is the reference as written in the page.
+	URL string
+	// AbsURL is URL resolved against the base URL of the page, by
+	// `<base href>` or the URL after redirects, without the fragment.
+	// It is empty if URL is invalid.
+	AbsURL string
+	Kind   LinkKind
+	// Text is the text of the anchor, including alt of images in it.
+	// For `<area>`, it is the alt attribute.
+	Text string
+	// Rel is the lowercased link types of the rel attribute,
+	// such as "nofollow", "sponsored" and "ugc".
+	Rel    []string
+	Target string
+	Title  string
+	// Line is the line number of the element in the page, starting at 1.
+	Line int
+	// Section is the name of the nearest landmark element that contains
+	// the element, "header", "nav", "main", "aside" or "footer",
+	// or empty if there is not.
+	Section string
+}
+
+// HasRel reports whether the link has the link type rel.
+func (l *Link) HasRel(rel string) bool {
+	for _, r := range l.Rel {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLinkKinds parses comma separated kinds of links.
+func ParseLinkKinds(s string) ([]LinkKind, error) {
+	kinds := make([]LinkKind, 0)
+	for _, k := range strings.Split(s, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		if !isLinkKind(LinkKind(k)) {
+			return nil, fmt.Errorf("%v: %s", ErrUnknownLinkKind, k)
+		}
+		kinds = append(kinds, LinkKind(k))
+	}
+	return kinds, nil
+}
+
+func isLinkKind(kind LinkKind) bool {
+	for _, k := range LinkKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// extractLinks returns links in body in document order, by tokenizing
+// body without building the DOM. AbsURL of links is resolved against the
+// first `<base href>` in body, or URL if there is not.
+func extractLinks(body []byte, URL *url.URL) []Link {
+	links := make([]Link, 0)
+	z := html.NewTokenizer(bytes.NewReader(body))
+	line := 1
+	var sections []string
+	// anchor is the index of the link of the open `<a>`, or -1.
+	anchor := -1
+	var text strings.Builder
+	closeAnchor := func() {
+		if anchor >= 0 {
+			links[anchor].Text = strings.Join(strings.Fields(text.String()), " ")
+		}
+		anchor = -1
+		text.Reset()
+	}
+	// raw is the element whose content is read as raw text.
+	var raw atom.Atom
+	// base is the href of the first `<base>`.
+	base := ""
+	for {
+		tt := z.Next()
+		start := line
+		line += bytes.Count(z.Raw(), []byte("\n"))
+		section := ""
+		if len(sections) > 0 {
+			section = sections[len(sections)-1]
+		}
+
+		switch tt {
+		case html.ErrorToken:
+			closeAnchor()
+			resolveLinks(links, documentBase(URL, base))
+			return links
+		case html.TextToken:
+			switch raw {
+			case atom.Style:
+				for _, l := range cssLinks(string(z.Text())) {
+					l.Line, l.Section = start, section
+					links = append(links, l)
+				}
+			case 0:
+				text.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			a := atom.Lookup(name)
+			var attrs []html.Attribute
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs = append(attrs, html.Attribute{Key: string(key), Val: string(val)})
+			}
+			if a == atom.A {
+				closeAnchor()
+			}
+			if href, ok := attrValue(attrs, "href"); a == atom.Base && ok && base == "" {
+				base = href
+			}
+			if a == atom.Img && anchor >= 0 {
+				alt, _ := attrValue(attrs, "alt")
+				text.WriteString(" " + alt + " ")
+			}
+			for _, l := range elementLinks(a, attrs) {
+				l.Line, l.Section = start, section
+				links = append(links, l)
+				if a == atom.A && l.Kind == LinkNavigation {
+					anchor = len(links) - 1
+				}
+			}
+			if tt == html.SelfClosingTagToken {
+				continue
+			}
+			switch a {
+			case atom.Header, atom.Nav, atom.Main, atom.Aside, atom.Footer:
+				sections = append(sections, a.String())
+			case atom.Script, atom.Style, atom.Textarea, atom.Title, atom.Noscript,
+				atom.Iframe, atom.Noembed, atom.Noframes, atom.Xmp:
+				raw = a
+				continue
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			a := atom.Lookup(name)
+			switch a {
+			case atom.A:
+				closeAnchor()
+			case atom.Header, atom.Nav, atom.Main, atom.Aside, atom.Footer:
+				for i := len(sections) - 1; i >= 0; i-- {
+					if sections[i] == a.String() {
+						sections = sections[:i]
+						break
+					}
+				}
+			}
+		}
+		raw = 0
+	}
+}
+
+// elementLinks returns links of an element from its attributes.
+func elementLinks(a atom.Atom, attrs []html.Attribute) []Link {
+	links := make([]Link, 0)
+	var rel []string
+	if v, ok := attrValue(attrs, "rel"); ok {
+		rel = strings.Fields(strings.ToLower(v))
+	}
+	target, _ := attrValue(attrs, "target")
+	title, _ := attrValue(attrs, "title")
+	newLink := func(u string, kind LinkKind) Link {
+		return Link{URL: u, Kind: kind, Rel: rel, Target: target, Title: title}
+	}
+	add := func(key string, kind LinkKind) {
+		if v, ok := attrValue(attrs, key); ok {
+			links = append(links, newLink(v, kind))
+		}
+	}
+	addSrcset := func(kind LinkKind) {
+		if v, ok := attrValue(attrs, "srcset"); ok {
+			for _, u := range srcsetURLs(v) {
+				links = append(links, newLink(u, kind))
+			}
+		}
+	}
+
+	switch a {
+	case atom.A:
+		add("href", LinkNavigation)
+	case atom.Area:
+		add("href", LinkNavigation)
+		if len(links) > 0 {
+			links[0].Text, _ = attrValue(attrs, "alt")
+		}
+	case atom.Link:
+		rel, _ := attrValue(attrs, "rel")
+		as, _ := attrValue(attrs, "as")
+		add("href", linkRelKind(rel, as))
+	case atom.Img:
+		add("src", LinkImage)
+		addSrcset(LinkImage)
+	case atom.Input:
+		if t, _ := attrValue(attrs, "type"); strings.EqualFold(t, "image") {
+			add("src", LinkImage)
+		}
+	case atom.Script:
+		add("src", LinkScript)
+	case atom.Iframe, atom.Frame:
+		add("src", LinkFrame)
+	case atom.Form:
+		add("action", LinkForm)
+	case atom.Meta:
+		if h, _ := attrValue(attrs, "http-equiv"); strings.EqualFold(h, "refresh") {
+			content, _ := attrValue(attrs, "content")
+			if u, ok := refreshURL(content); ok {
+				links = append(links, newLink(u, LinkRedirect))
+			}
+		}
+	case atom.Video:
+		add("src", LinkMedia)
+		add("poster", LinkImage)
+	case atom.Audio, atom.Track:
+		add("src", LinkMedia)
+	case atom.Source:
+		add("src", LinkMedia)
+		addSrcset(LinkImage)
+	}
+	if style, ok := attrValue(attrs, "style"); ok {
+		links = append(links, cssLinks(style)...)
+	}
+	return links
+}
+
+// attrValue returns the value of the first attribute with key.
+func attrValue(attrs []html.Attribute, key string) (string, bool) {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// linkRelKind returns the kind of `<link>` by its rel and as attributes.
+// Link types of resources take precedence over link types of pages, so that
+// `rel="alternate stylesheet"` is a stylesheet. Unknown link types are LinkOther.
+func linkRelKind(rel, as string) LinkKind {
+	rels := strings.Fields(strings.ToLower(rel))
+	for _, r := range rels {
+		switch r {
+		case "stylesheet":
+			return LinkStylesheet
+		case "icon", "apple-touch-icon", "apple-touch-icon-precomposed", "mask-icon":
+			return LinkImage
+		case "modulepreload":
+			return LinkScript
+		case "preconnect", "dns-prefetch":
+			return LinkHint
+		case "preload", "prefetch":
+			switch strings.ToLower(as) {
+			case "style":
+				return LinkStylesheet
+			case "script":
+				return LinkScript
+			case "image", "font":
+				return LinkImage
+			case "audio", "video", "track":
+				return LinkMedia
+			}
+			return LinkOther
+		}
+	}
+	for _, r := range rels {
+		switch r {
+		case "alternate", "canonical", "next", "prev", "previous", "first", "last",
+			"up", "home", "index", "start", "contents", "toc", "help":
+			return LinkNavigation
+		}
+	}
+	return LinkOther
+}
+
+// srcsetURLs returns URLs of image candidates in srcset.
+func srcsetURLs(srcset string) []string {
+	urls := make([]string, 0)
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return urls
+		}
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end < 0 {
+			end = len(s)
+		}
+		u := s[:end]
+		s = s[end:]
+		if strings.HasSuffix(u, ",") {
+			// The candidate has no descriptors.
+			u = strings.TrimRight(u, ",")
+		} else if i := strings.IndexByte(s, ','); i >= 0 {
+			s = s[i+1:]
+		} else {
+			s = ""
+		}
+		urls = append(urls, u)
+	}
+}
+
+var refreshURLPrefix = regexp.MustCompile(`(?i)^url\s*=\s*`)
+
+// refreshURL returns the URL in the content of `<meta http-equiv="refresh">`
+// such as "5; url=/next".
+func refreshURL(content string) (string, bool) {
+	i := strings.IndexAny(content, ";,")
+	if i < 0 {
+		return "", false
+	}
+	u := strings.TrimSpace(content[i+1:])
+	u = refreshURLPrefix.ReplaceAllString(u, "")
+	if len(u) > 0 && (u[0] == '"' || u[0] == '\'') {
+		if j := strings.IndexByte(u[1:], u[0]); j >= 0 {
+			u = u[1 : j+1]
+		} else {
+			u = u[1:]
+		}
+	}
+	u = strings.TrimSpace(u)
+	return u, u != ""
+}
+
+var (
+	cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssImport  = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*)?(?:"([^"]*)"|'([^']*)'|([^\s"');]+))`)
+	cssURL     = regexp.MustCompile(`(?i)\burl\(\s*(?:"([^"]*)"|'([^']*)'|([^\s"')]*))\s*\)`)
+)
+
+// cssLinks returns links by `@import` and `url()` in css.
+func cssLinks(css string) []Link {
+	links := make([]Link, 0)
+	css = cssComment.ReplaceAllString(css, "")
+	imports := cssImport.FindAllStringSubmatchIndex(css, -1)
+	for _, m := range imports {
+		if u := submatch(css, m); u != "" {
+			links = append(links, Link{URL: u, Kind: LinkStylesheet})
+		}
+	}
+	for _, m := range cssURL.FindAllStringSubmatchIndex(css, -1) {
+		imported := false
+		for _, im := range imports {
+			if m[0] >= im[0] && m[0] < im[1] {
+				imported = true
+				break
+			}
+		}
+		if u := submatch(css, m); u != "" && !imported {
+			links = append(links, Link{URL: u, Kind: LinkImage})
+		}
+	}
+	return links
+}
+
+// submatch returns the first matched group of m.
+func submatch(s string, m []int) string {
+	for i := 2; i+1 < len(m); i += 2 {
+		if m[i] >= 0 {
+			return strings.TrimSpace(s[m[i]:m[i+1]])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,335 @@
+package crawler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type kindLink struct {
+	URL  string
+	Kind LinkKind
+}
+
+func kindLinks(links []Link) []kindLink {
+	kls := make([]kindLink, len(links))
+	for i, l := range links {
+		kls[i] = kindLink{l.URL, l.Kind}
+	}
+	return kls
+}
+
+func TestExtractLinks(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/crawl-links.html")
+	assert.NoError(t, err)
+	base, _ := url.Parse("https://test.com/dir/")
+	links := extractLinks(b, base)
+	want := []kindLink{
+		{"/refresh", LinkRedirect},
+		{"/style.css", LinkStylesheet},
+		{"/favicon.ico", LinkImage},
+		{"/app.js", LinkScript},
+		{"/page2", LinkNavigation},
+		{"/import.css", LinkStylesheet},
+		{"/bg.png", LinkImage},
+		{"/app.js", LinkScript},
+		{"/page1", LinkNavigation},
+		{"/area", LinkNavigation},
+		{"/img.png", LinkImage},
+		{"/img-1x.png", LinkImage},
+		{"/img-2x.png", LinkImage},
+		{"/pic.webp", LinkImage},
+		{"/frame", LinkFrame},
+		{"/search", LinkForm},
+		{"/button.png", LinkImage},
+		{"/movie.mp4", LinkMedia},
+		{"/poster.jpg", LinkImage},
+		{"/sub.vtt", LinkMedia},
+		{"/div.png", LinkImage},
+	}
+	assert.Equal(t, want, kindLinks(links))
+	assert.Equal(t, "https://test.com/page1", links[8].AbsURL)
+	assert.Equal(t, 4, links[0].Line)
+	assert.Equal(t, 9, links[6].Line)
+
+	bodies := map[string][]kindLink{
+		`<a href="/a">a</a><A HREF='/b'/><a name="top"></a><a href="/c&amp;d" href="/e">c</a>`: {
+			{"/a", LinkNavigation}, {"/b", LinkNavigation}, {"/c&d", LinkNavigation},
+		},
+		`<script>document.write('<a href="/script">')</script><textarea><a href="/text"></textarea><a href="/f">`: {
+			{"/f", LinkNavigation},
+		},
+		`<style>p { background: url(/p.png) }</style><style></style><p style="background: url(/q.png)">`: {
+			{"/p.png", LinkImage}, {"/q.png", LinkImage},
+		},
+	}
+	for body, want := range bodies {
+		assert.Equal(t, want, kindLinks(extractLinks([]byte(body), base)), body)
+	}
+}
+
+func TestExtractLinksBase(t *testing.T) {
+	URL, _ := url.Parse("https://test.com/dir/page")
+	bodies := map[string]string{
+		`<a href="a">a</a><base href="/docs/"><base href="/other/">`: "https://test.com/docs/a",
+		`<base href="https://cdn.test.com/"><a href="a">a</a>`:       "https://cdn.test.com/a",
+		`<base target="_blank"><a href="a">a</a>`:                    "https://test.com/dir/a",
+		`<base href="http://[::1"><a href="a">a</a>`:                 "https://test.com/dir/a",
+	}
+	for body, want := range bodies {
+		links := extractLinks([]byte(body), URL)
+		if assert.Len(t, links, 1, body) {
+			assert.Equal(t, want, links[0].AbsURL, body)
+		}
+	}
+}
+
+// domLinks returns links of the elements and style sheets in the DOM of body.
+func domLinks(t *testing.T, body string) []kindLink {
+	t.Helper()
+	root, err := html.Parse(strings.NewReader(body))
+	assert.NoError(t, err)
+	links := make([]Link, 0)
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			links = append(links, elementLinks(n.DataAtom, n.Attr)...)
+			if n.DataAtom == atom.Style && n.FirstChild != nil {
+				links = append(links, cssLinks(n.FirstChild.Data)...)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return kindLinks(links)
+}
+
+// TestExtractLinksDOM tests that the tokenizer finds the same links as the DOM.
+func TestExtractLinksDOM(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.html")
+	assert.NoError(t, err)
+	bodies := []string{
+		`<a href="/a">a</a><A HREF='/b'/><a name="top"></a><a href="/c&amp;d" href="/e">c</a>`,
+		`<script>document.write('<a href="/script">')</script><textarea><a href="/text"></textarea><a href="/f">`,
+		`<svg><a href="/svg"></a></svg><template><a href="/template"></a></template><a href="/g"`,
+		`<title><a href="/title"></title><noscript><img src="/noscript.png"></noscript><xmp><a href="/xmp"></xmp>`,
+		`<style>/* <a href="/comment"> */ p { background: url(/p.png) }</style><iframe src="/frame"><a href="/x"></iframe>`,
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		assert.NoError(t, err)
+		bodies = append(bodies, string(b))
+	}
+	base, _ := url.Parse("https://test.com/")
+	for _, body := range bodies {
+		assert.Equal(t, domLinks(t, body), kindLinks(extractLinks([]byte(body), base)), body)
+	}
+}
+
+func TestExtractLinksResolve(t *testing.T) {
+	base, _ := url.Parse("https://h/docs/guide/index.html")
+	testCases := []struct {
+		href string
+		want string
+	}{
+		{"intro.html", "https://h/docs/guide/intro.html"},
+		{"./a/../b.html", "https://h/docs/guide/b.html"},
+		{"../api/", "https://h/docs/api/"},
+		{"../../../up", "https://h/up"},
+		{"/docs/../admin/", "https://h/admin/"},
+		{"?page=2", "https://h/docs/guide/index.html?page=2"},
+		{"//other.com/x", "https://other.com/x"},
+		{"/abs#frag", "https://h/abs"},
+		{"#top", "https://h/docs/guide/index.html"},
+		{" https://example.com/a ", "https://example.com/a"},
+		{"http://[::1", ""},
+	}
+
+	for _, tt := range testCases {
+		body := fmt.Sprintf(`<a href="%s">link</a>`, tt.href)
+		links := extractLinks([]byte(body), base)
+		if assert.Len(t, links, 1, tt.href) {
+			assert.Equal(t, tt.want, links[0].AbsURL, tt.href)
+		}
+	}
+}
+
+func TestExtractLinksMetadata(t *testing.T) {
+	body := `<header><nav>
+<a href="/home" title="Home page">  Home
+  page </a>
+</nav></header>
+<main><p><a href="https://ad.test.com/" rel="Nofollow sponsored" target="_blank"><img src="/ad.png" alt="Ad"> banner</a>
+<map><area href="map" alt="Map area"></map></p></main>
+<footer><a href="/about">About <b>us</b></footer>`
+	base, _ := url.Parse("https://test.com/dir/page")
+	links := extractLinks([]byte(body), base)
+	want := []Link{
+		{URL: "/home", AbsURL: "https://test.com/home", Kind: LinkNavigation, Text: "Home page", Title: "Home page", Line: 2, Section: "nav"},
+		{URL: "https://ad.test.com/", AbsURL: "https://ad.test.com/", Kind: LinkNavigation, Text: "Ad banner",
+			Rel: []string{"nofollow", "sponsored"}, Target: "_blank", Line: 5, Section: "main"},
+		{URL: "/ad.png", AbsURL: "https://test.com/ad.png", Kind: LinkImage, Line: 5, Section: "main"},
+		{URL: "map", AbsURL: "https://test.com/dir/map", Kind: LinkNavigation, Text: "Map area", Line: 6, Section: "main"},
+		{URL: "/about", AbsURL: "https://test.com/about", Kind: LinkNavigation, Text: "About us", Line: 7, Section: "footer"},
+	}
+	assert.Equal(t, want, links)
+	assert.True(t, links[1].HasRel("nofollow"))
+	assert.False(t, links[0].HasRel("nofollow"))
+}
+
+func TestSrcsetURLs(t *testing.T) {
+	tests := map[string][]string{
+		"a.png":                       {"a.png"},
+		"a.png 1x, b.png 2x":          {"a.png", "b.png"},
+		" a.png 100w,b.png 200w ":     {"a.png", "b.png"},
+		"a.png,b.png":                 {"a.png,b.png"},
+		"a.png, b.png":                {"a.png", "b.png"},
+		"data:image/png;base64,AA 1x": {"data:image/png;base64,AA"},
+		"":                            {},
+	}
+	for srcset, want := range tests {
+		assert.Equal(t, want, srcsetURLs(srcset), srcset)
+	}
+}
+
+func TestRefreshURL(t *testing.T) {
+	tests := []struct {
+		content, want string
+		ok            bool
+	}{
+		{"0; url=/next", "/next", true},
+		{"5;URL='/quoted'", "/quoted", true},
+		{"1, \"/double\"", "/double", true},
+		{"10", "", false},
+		{"0; url=", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := refreshURL(tt.content)
+		assert.Equal(t, tt.want, got, tt.content)
+		assert.Equal(t, tt.ok, ok, tt.content)
+	}
+}
+
+func TestLinkRelKind(t *testing.T) {
+	tests := []struct {
+		rel, as string
+		want    LinkKind
+	}{
+		{"stylesheet", "", LinkStylesheet},
+		{"alternate stylesheet", "", LinkStylesheet},
+		{"Shortcut Icon", "", LinkImage},
+		{"preload", "script", LinkScript},
+		{"preload", "font", LinkImage},
+		{"preload", "fetch", LinkOther},
+		{"prefetch", "document", LinkOther},
+		{"preconnect", "", LinkHint},
+		{"dns-prefetch", "", LinkHint},
+		{"alternate", "", LinkNavigation},
+		{"canonical", "", LinkNavigation},
+		{"next", "", LinkNavigation},
+		{"prev", "", LinkNavigation},
+		{"home", "", LinkNavigation},
+		{"manifest", "", LinkOther},
+		{"pingback", "", LinkOther},
+		{"EditURI", "", LinkOther},
+		{"search", "", LinkOther},
+		{"", "", LinkOther},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, linkRelKind(tt.rel, tt.as), tt.rel+" "+tt.as)
+	}
+}
+
+func TestCSSLinks(t *testing.T) {
+	css := `@import "a.css"; @import url(b.css) screen; @import 'c.css';
+/* url(comment.png) */
+.x { background: url( "x.png" ) } .y { src: url(font.woff2) format("woff2") } .z { background: url() }`
+	want := []kindLink{
+		{"a.css", LinkStylesheet},
+		{"b.css", LinkStylesheet},
+		{"c.css", LinkStylesheet},
+		{"x.png", LinkImage},
+		{"font.woff2", LinkImage},
+	}
+	assert.Equal(t, want, kindLinks(cssLinks(css)))
+}
+
+func TestParseLinkKinds(t *testing.T) {
+	kinds, err := ParseLinkKinds("navigation, image,,frame")
+	assert.NoError(t, err)
+	assert.Equal(t, []LinkKind{LinkNavigation, LinkImage, LinkFrame}, kinds)
+
+	_, err = ParseLinkKinds("navigation,video")
+	assert.Error(t, err)
+
+	_, err = ParseLinkKinds("hint")
+	assert.Error(t, err)
+}
+
+func TestCrawlFollowKinds(t *testing.T) {
+	ts := newTestServer(t, "testdata/crawl.html")
+	defer ts.Close()
+
+	crawl := func(kinds ...LinkKind) []string {
+		c := NewCrawler(ts.URL, 2)
+		if kinds != nil {
+			c.SetFollowKinds(kinds...)
+		}
+		var mux sync.Mutex
+		visited := make([]string, 0)
+		c.OnVisited(func(cr *CrawlResult) {
+			mux.Lock()
+			defer mux.Unlock()
+			visited = append(visited, cr.URL.String())
+		})
+		c.Crawl()
+		return visited
+	}
+
+	assert.ElementsMatch(t, []string{
+		ts.URL,
+		ts.URL + "/image/test1.png",
+		ts.URL + "/image/test2.jpg",
+		ts.URL + "/relative/test3",
+	}, crawl())
+	assert.ElementsMatch(t, []string{
+		ts.URL,
+		ts.URL + "/css/styles.css?v=1.0",
+		ts.URL + "/js/scripts.js",
+	}, crawl(LinkStylesheet, LinkScript))
+}
+
+func TestCrawlSkipNofollow(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/follow">follow</a><a href="/nofollow" rel="nofollow">nofollow</a>`)
+		}
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	c := NewCrawler(ts.URL, 2)
+	c.SetSkipNofollow(true)
+	var mux sync.Mutex
+	visited := make([]string, 0)
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		visited = append(visited, cr.URL.String())
+	})
+	c.Crawl()
+	assert.ElementsMatch(t, []string{ts.URL, ts.URL + "/follow"}, visited)
+}
@@ -0,0 +1,341 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LinkExtractor extracts links from a response body.
+// URL is the URL of the page which has the body.
+type LinkExtractor interface {
+	ExtractLinks(body []byte, URL *url.URL) ([]Link, error)
+}
+
+// LinkExtractorFunc is an adapter to use a function as LinkExtractor.
+type LinkExtractorFunc func(body []byte, URL *url.URL) ([]Link, error)
+
+// ExtractLinks calls f(body, URL).
+func (f LinkExtractorFunc) ExtractLinks(body []byte, URL *url.URL) ([]Link, error) {
+	return f(body, URL)
+}
+
+// ErrLinkExtraction is the error for a body which links can not be extracted from.
+var ErrLinkExtraction = errors.New("Failed to extract links")
+
+// defaultLinkExtractors returns the built-in link extractors by media type.
+func defaultLinkExtractors() map[string]LinkExtractor {
+	m := map[string]LinkExtractor{}
+	for _, t := range []string{"text/html", "application/xhtml+xml"} {
+		m[t] = LinkExtractorFunc(HTMLLinks)
+	}
+	m["text/css"] = LinkExtractorFunc(CSSLinks)
+	for _, t := range []string{"application/xml", "text/xml", "application/rss+xml", "application/atom+xml"} {
+		m[t] = LinkExtractorFunc(XMLLinks)
+	}
+	m["text/plain"] = LinkExtractorFunc(TextLinks)
+	m["application/json"] = LinkExtractorFunc(JSONLinks)
+	m["application/pdf"] = LinkExtractorFunc(PDFLinks)
+	return m
+}
+
+// mediaType returns the lowercased media type of contentType without parameters.
+func mediaType(contentType string) string {
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		return t
+	}
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
+
+// IsHTML reports whether contentType is HTML or XHTML. Empty contentType,
+// such as of results saved without it, is regarded as HTML.
+func IsHTML(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	t := mediaType(contentType)
+	return t == "text/html" || t == "application/xhtml+xml"
+}
+
+// linkExtractor returns the link extractor of contentType, or nil if there is not.
+// Types with a structured syntax suffix such as "application/ld+json" fall back
+// to the extractor of "application/json" or "application/xml".
+func (c *Crawler) linkExtractor(contentType string) LinkExtractor {
+	t := mediaType(contentType)
+	if e, ok := c.linkExtractors[t]; ok {
+		return e
+	}
+	switch {
+	case strings.HasSuffix(t, "+json"):
+		return c.linkExtractors["application/json"]
+	case strings.HasSuffix(t, "+xml"):
+		return c.linkExtractors["application/xml"]
+	}
+	return nil
+}
+
+// resolveLinks sets AbsURL of links resolved against URL if it is empty.
+func resolveLinks(links []Link, URL *url.URL) {
+	for i := range links {
+		if links[i].AbsURL == "" {
+			links[i].AbsURL = resolveURL(URL, links[i].URL)
+		}
+	}
+}
+
+// documentBase returns the base URL of the document at URL with
+// `<base href>`, or URL if href is empty or invalid.
+func documentBase(URL *url.URL, href string) *url.URL {
+	if href = strings.TrimSpace(href); href == "" {
+		return URL
+	}
+	if base, err := URL.Parse(href); err == nil {
+		return base
+	}
+	return URL
+}
+
+// resolveURL returns ref resolved against base without the fragment,
+// or "" if ref is not a valid URL.
+func resolveURL(base *url.URL, ref string) string {
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// HTMLLinks returns links of HTML elements and CSS in HTML.
+// Links are resolved against `<base href>` if the page has it.
+func HTMLLinks(body []byte, URL *url.URL) ([]Link, error) {
+	return extractLinks(body, URL), nil
+}
+
+// CSSLinks returns links by `@import` and `url()` in CSS.
+func CSSLinks(body []byte, URL *url.URL) ([]Link, error) {
+	links := cssLinks(string(body))
+	resolveLinks(links, URL)
+	return links, nil
+}
+
+// XMLLinks returns links in XML documents such as RSS, Atom and sitemaps:
+// href attributes, text of link and loc elements, url attributes of
+// enclosures, and href of xml-stylesheet.
+func XMLLinks(body []byte, URL *url.URL) ([]Link, error) {
+	links := make([]Link, 0)
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+	d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	// path is the local names of open elements.
+	var path []string
+	var text strings.Builder
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			text.Reset()
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Local == "href":
+					links = append(links, Link{URL: strings.TrimSpace(a.Value), Kind: LinkNavigation})
+				case a.Name.Local == "url" && (t.Name.Local == "enclosure" || t.Name.Local == "content"):
+					links = append(links, Link{URL: strings.TrimSpace(a.Value), Kind: LinkMedia})
+				}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			u := strings.TrimSpace(text.String())
+			text.Reset()
+			n := len(path)
+			if n == 0 {
+				continue
+			}
+			switch {
+			case u == "":
+			case path[n-1] == "link" || path[n-1] == "loc":
+				links = append(links, Link{URL: u, Kind: LinkNavigation})
+			case path[n-1] == "url" && n > 1 && path[n-2] == "image":
+				links = append(links, Link{URL: u, Kind: LinkImage})
+			}
+			path = path[:n-1]
+		case xml.ProcInst:
+			if t.Target == "xml-stylesheet" {
+				if m := piHref.FindSubmatch(t.Inst); m != nil {
+					links = append(links, Link{URL: string(m[1]) + string(m[2]), Kind: LinkStylesheet})
+				}
+			}
+		}
+	}
+	resolveLinks(links, URL)
+	return links, nil
+}
+
+var piHref = regexp.MustCompile(`href\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// textURL matches absolute http and https URLs in text.
+var textURL = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'` + "`" + `]+`)
+
+// TextLinks returns absolute http and https URLs in plain text.
+// Punctuation at the end of URLs and unbalanced closing parentheses are not
+// included. Line of links is the line of the URL.
+func TextLinks(body []byte, URL *url.URL) ([]Link, error) {
+	links := make([]Link, 0)
+	for i, line := range strings.Split(string(body), "\n") {
+		for _, u := range textURL.FindAllString(line, -1) {
+			u = trimTextURL(u)
+			links = append(links, Link{URL: u, Kind: LinkNavigation, Line: i + 1})
+		}
+	}
+	resolveLinks(links, URL)
+	return links, nil
+}
+
+// trimTextURL removes punctuation which ends the sentence around u.
+func trimTextURL(u string) string {
+	for {
+		trimmed := strings.TrimRight(u, ".,;:!?")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+		if trimmed == u {
+			return u
+		}
+		u = trimmed
+	}
+}
+
+// JSONLinks returns string values of JSON that are absolute http and https URLs.
+// Values of objects are visited in the order of keys.
+func JSONLinks(body []byte, URL *url.URL) ([]Link, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	links := make([]Link, 0)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			if isAbsHTTPURL(v) {
+				links = append(links, Link{URL: v, Kind: LinkNavigation})
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(v[k])
+			}
+		}
+	}
+	walk(v)
+	resolveLinks(links, URL)
+	return links, nil
+}
+
+func isAbsHTTPURL(s string) bool {
+	if strings.ContainsAny(s, " \t\r\n") {
+		return false
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// maxPDFStreamSize and maxPDFInflatedSize limit the inflated size of
+// each stream and of every stream in a PDF.
+const (
+	maxPDFStreamSize   = 16 << 20
+	maxPDFInflatedSize = 64 << 20
+)
+
+var (
+	pdfURI    = regexp.MustCompile(`/URI\s*\(((?:[^()\\]|\\.)*)\)`)
+	pdfStream = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\n?endstream`)
+)
+
+// PDFLinks returns URIs of link annotations in PDF. URIs in compressed
+// object streams are found by inflating streams. Streams are inflated up
+// to maxPDFStreamSize each and maxPDFInflatedSize in total, and a corrupt
+// stream is searched as far as it is inflated.
+func PDFLinks(body []byte, URL *url.URL) ([]Link, error) {
+	links := make([]Link, 0)
+	add := func(b []byte) {
+		for _, m := range pdfURI.FindAllSubmatch(b, -1) {
+			links = append(links, Link{URL: unescapePDFString(m[1]), Kind: LinkNavigation})
+		}
+	}
+	add(body)
+	remaining := int64(maxPDFInflatedSize)
+	for _, m := range pdfStream.FindAllSubmatch(body, -1) {
+		if remaining <= 0 {
+			break
+		}
+		r, err := zlib.NewReader(bytes.NewReader(m[1]))
+		if err != nil {
+			continue
+		}
+		limit := int64(maxPDFStreamSize)
+		if remaining < limit {
+			limit = remaining
+		}
+		// A corrupt stream fails to inflate, but its inflated part is searched.
+		b, _ := ioutil.ReadAll(io.LimitReader(r, limit))
+		remaining -= int64(len(b))
+		add(b)
+	}
+	resolveLinks(links, URL)
+	return links, nil
+}
+
+// unescapePDFString unescapes backslash escapes of a literal string in PDF.
+func unescapePDFString(b []byte) string {
+	var s strings.Builder
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\\' || i+1 == len(b) {
+			s.WriteByte(b[i])
+			continue
+		}
+		i++
+		switch b[i] {
+		case 'n':
+			s.WriteByte('\n')
+		case 'r':
+			s.WriteByte('\r')
+		case 't':
+			s.WriteByte('\t')
+		case '\r', '\n':
+			// Line continuation.
+		default:
+			s.WriteByte(b[i])
+		}
+	}
+	return s.String()
+}
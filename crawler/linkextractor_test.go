@@ -0,0 +1,220 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func absURLs(links []Link) []string {
+	urls := make([]string, len(links))
+	for i, l := range links {
+		urls[i] = l.AbsURL
+	}
+	return urls
+}
+
+func TestXMLLinks(t *testing.T) {
+	base, _ := url.Parse("https://test.com/feed")
+	rss := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<?xml-stylesheet type="text/xsl" href="/feed.xsl"?>
+<rss version="2.0"><channel>
+  <link>https://test.com/</link>
+  <image><url>https://test.com/logo.png</url><link>https://test.com/</link></image>
+  <item><link><![CDATA[ /posts/1 ]]></link><enclosure url="/podcast.mp3" type="audio/mpeg"/></item>
+</channel></rss>`
+	links, err := XMLLinks([]byte(rss), base)
+	assert.NoError(t, err)
+	assert.Equal(t, []kindLink{
+		{"/feed.xsl", LinkStylesheet},
+		{"https://test.com/", LinkNavigation},
+		{"https://test.com/logo.png", LinkImage},
+		{"https://test.com/", LinkNavigation},
+		{"/posts/1", LinkNavigation},
+		{"/podcast.mp3", LinkMedia},
+	}, kindLinks(links))
+	assert.Equal(t, "https://test.com/posts/1", links[4].AbsURL)
+
+	atom := `<feed xmlns="http://www.w3.org/2005/Atom"><link rel="self" href="https://test.com/atom"/>
+<entry><link href="/posts/2"/></entry></feed>`
+	links, err = XMLLinks([]byte(atom), base)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://test.com/atom", "https://test.com/posts/2"}, absURLs(links))
+
+	sitemap := `<urlset><url><loc>https://test.com/a</loc></url><url><loc>https://test.com/b</loc></url></urlset>`
+	links, err = XMLLinks([]byte(sitemap), base)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://test.com/a", "https://test.com/b"}, absURLs(links))
+
+	_, err = XMLLinks([]byte(`<rss><link href="/a`), base)
+	assert.Error(t, err)
+}
+
+func TestCSSLinksResolve(t *testing.T) {
+	base, _ := url.Parse("https://h/static/css/site.css")
+	css := `@import "print.css"; @import url(../base.css);
+body { background: url(../img/bg.png) } .logo { background: url('//cdn.example/a.png') }
+.icon { background: url("/icons/i.svg#x") }`
+	links, err := CSSLinks([]byte(css), base)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://h/static/css/print.css",
+		"https://h/static/base.css",
+		"https://h/static/img/bg.png",
+		"https://cdn.example/a.png",
+		"https://h/icons/i.svg",
+	}, absURLs(links))
+}
+
+func TestTextLinks(t *testing.T) {
+	base, _ := url.Parse("https://test.com/readme.txt")
+	text := "See https://test.com/docs.\n(and http://test.com/a_(b)) or <https://other.test.com/?q=1>!\nftp://no.test.com/"
+	links, err := TextLinks([]byte(text), base)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://test.com/docs", "http://test.com/a_(b)", "https://other.test.com/?q=1"}, absURLs(links))
+	assert.Equal(t, []int{1, 2, 2}, []int{links[0].Line, links[1].Line, links[2].Line})
+}
+
+func TestJSONLinks(t *testing.T) {
+	base, _ := url.Parse("https://test.com/api")
+	body := `{"next": "https://test.com/api?page=2", "items": [{"url": "http://test.com/1", "name": "not url"}],
+"path": "/relative", "count": 2, "b": "https://test.com/b"}`
+	links, err := JSONLinks([]byte(body), base)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://test.com/b", "http://test.com/1", "https://test.com/api?page=2"}, absURLs(links))
+
+	_, err = JSONLinks([]byte(`{"a":`), base)
+	assert.Error(t, err)
+}
+
+// pdfStreamObject returns a PDF object of the stream of compressed data.
+func pdfStreamObject(t *testing.T, data ...string) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	for _, d := range data {
+		_, err := io.WriteString(w, d)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	var obj bytes.Buffer
+	fmt.Fprint(&obj, "2 0 obj << /Type /ObjStm /Filter /FlateDecode >>\nstream\n")
+	obj.Write(compressed.Bytes())
+	obj.WriteString("\nendstream\nendobj\n")
+	return obj.Bytes()
+}
+
+func TestPDFLinks(t *testing.T) {
+	var pdf bytes.Buffer
+	fmt.Fprint(&pdf, "%PDF-1.5\n1 0 obj << /Type /Annot /A << /S /URI /URI (https://test.com/a\\(1\\)) >> >> endobj\n")
+	pdf.Write(pdfStreamObject(t, strings.Repeat("0 0 obj ", 20)+`<< /A << /S /URI /URI (https://test.com/compressed) >> >>`))
+	pdf.WriteString("%%EOF")
+
+	base, _ := url.Parse("https://test.com/doc.pdf")
+	links, err := PDFLinks(pdf.Bytes(), base)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://test.com/a(1)", "https://test.com/compressed"}, absURLs(links))
+}
+
+func TestPDFLinksInflateLimit(t *testing.T) {
+	uri := func(path string) string {
+		return `<< /A << /S /URI /URI (https://test.com/` + path + `) >> >>`
+	}
+	padding := strings.Repeat("0", maxPDFStreamSize-100)
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.5\n")
+	// The end of the oversized stream is not inflated.
+	pdf.Write(pdfStreamObject(t, uri("head"), padding, strings.Repeat("0", 200), uri("tail")))
+	// Streams after maxPDFInflatedSize are not inflated.
+	for i := 1; i <= maxPDFInflatedSize/maxPDFStreamSize; i++ {
+		pdf.Write(pdfStreamObject(t, padding, uri(fmt.Sprint(i))))
+	}
+	pdf.Write(pdfStreamObject(t, strings.Repeat("0 0 obj ", 20), uri("last")))
+	pdf.WriteString("%%EOF")
+
+	base, _ := url.Parse("https://test.com/doc.pdf")
+	links, err := PDFLinks(pdf.Bytes(), base)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://test.com/head", "https://test.com/1", "https://test.com/2", "https://test.com/3"}, absURLs(links))
+}
+
+func TestCrawlerLinkExtractor(t *testing.T) {
+	c := NewCrawler("https://test.com/", 1)
+	html := c.linkExtractor("text/html; charset=utf-8")
+	assert.NotNil(t, html)
+	assert.NotNil(t, c.linkExtractor("application/ld+json"))
+	assert.NotNil(t, c.linkExtractor("application/vnd.foo+xml"))
+	assert.Nil(t, c.linkExtractor("image/png"))
+
+	c.SetLinkExtractor("Image/PNG", html)
+	assert.NotNil(t, c.linkExtractor("image/png"))
+	c.SetLinkExtractor("text/html", nil)
+	assert.Nil(t, c.linkExtractor("text/html"))
+}
+
+func TestCrawlNonHTMLLinks(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"links": ["%s/style.css", "%s/broken"]}`, ts.URL, ts.URL)
+		case "/style.css":
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(w, `@import "print.css";`)
+		case "/broken":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{`)
+		default:
+			w.Header().Set("Content-Type", "text/css")
+		}
+	}))
+	defer ts.Close()
+
+	c := NewCrawler(ts.URL, 3)
+	c.SetFollowKinds(LinkKinds...)
+	var mux sync.Mutex
+	visited := make([]string, 0)
+	errs := make([]error, 0)
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		visited = append(visited, cr.URL.String())
+	})
+	c.OnError(func(err error) {
+		mux.Lock()
+		defer mux.Unlock()
+		errs = append(errs, err)
+	})
+	c.Crawl()
+
+	assert.ElementsMatch(t, []string{ts.URL, ts.URL + "/style.css", ts.URL + "/broken", ts.URL + "/print.css"}, visited)
+	if assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[0].Error(), ErrLinkExtraction.Error())
+	}
+}
+
+func TestIsHTML(t *testing.T) {
+	tests := map[string]bool{
+		"":                          true,
+		"text/html":                 true,
+		"Text/HTML; charset=UTF-8":  true,
+		"application/xhtml+xml":     true,
+		"text/plain":                false,
+		"application/xml":           false,
+		"text/htmlx; charset=utf-8": false,
+	}
+	for contentType, want := range tests {
+		assert.Equal(t, want, IsHTML(contentType), contentType)
+	}
+}
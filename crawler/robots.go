@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/greytabby/grawl/scrape"
+)
+
+// robotsHeader is the header of robots directives.
+const robotsHeader = "X-Robots-Tag"
+
+// head is the robots directives, canonical URL and base URL in the head of a page.
+type head struct {
+	// robots is the contents of `<meta name="robots">`.
+	robots []string
+	// canonical is the href of the first `<link rel="canonical">`.
+	canonical string
+	// base is the href of the first `<base>`.
+	base string
+}
+
+// scanHead returns the head of body by tokenizing it until `<body>`.
+func scanHead(body []byte) *head {
+	h := &head{}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return h
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			a := atom.Lookup(name)
+			if a == atom.Body {
+				return h
+			}
+			if a != atom.Meta && a != atom.Link && a != atom.Base {
+				continue
+			}
+			var attrs []html.Attribute
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs = append(attrs, html.Attribute{Key: string(key), Val: string(val)})
+			}
+			if n, _ := attrValue(attrs, "name"); a == atom.Meta && strings.EqualFold(n, "robots") {
+				content, _ := attrValue(attrs, "content")
+				h.robots = append(h.robots, content)
+			}
+			rel, _ := attrValue(attrs, "rel")
+			href, ok := attrValue(attrs, "href")
+			if a == atom.Link && ok && h.canonical == "" && scrape.HasToken(rel, "canonical") {
+				h.canonical = strings.TrimSpace(href)
+			}
+			if a == atom.Base && ok && h.base == "" {
+				h.base = href
+			}
+		}
+	}
+}
+
+// robotsDirectives returns noindex and nofollow directives of the page by
+// `<meta name="robots">` and X-Robots-Tag header. Directives of the header
+// for a specific crawler, such as "googlebot: noindex", are ignored.
+func robotsDirectives(h *head, header http.Header) (noindex, nofollow bool) {
+	values := append([]string{}, h.robots...)
+	for _, v := range header[http.CanonicalHeaderKey(robotsHeader)] {
+		if i := strings.IndexByte(v, ':'); i >= 0 && !strings.Contains(v[:i], ",") && !isRobotsDirective(v[:i]) {
+			continue
+		}
+		values = append(values, v)
+	}
+	for _, v := range values {
+		for _, d := range strings.Split(v, ",") {
+			switch strings.ToLower(strings.TrimSpace(d)) {
+			case "noindex":
+				noindex = true
+			case "nofollow":
+				nofollow = true
+			case "none":
+				noindex, nofollow = true, true
+			}
+		}
+	}
+	return noindex, nofollow
+}
+
+// isRobotsDirective reports whether name is a robots directive with a value.
+func isRobotsDirective(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "unavailable_after", "max-snippet", "max-image-preview", "max-video-preview":
+		return true
+	}
+	return false
+}
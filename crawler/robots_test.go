@@ -0,0 +1,123 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanHead(t *testing.T) {
+	body := `<html><head>
+<meta name="ROBOTS" content="noindex">
+<link rel="alternate" href="/en">
+<link rel="Canonical" href=" /page ">
+<link rel="canonical" href="/other">
+<base href="/docs/"><base href="/other/">
+</head><body><meta name="robots" content="nofollow"></body></html>`
+	h := scanHead([]byte(body))
+	assert.Equal(t, []string{"noindex"}, h.robots)
+	assert.Equal(t, "/page", h.canonical)
+	assert.Equal(t, "/docs/", h.base)
+
+	h = scanHead([]byte(`<p>no head`))
+	assert.Empty(t, h.robots)
+	assert.Equal(t, "", h.canonical)
+	assert.Equal(t, "", h.base)
+}
+
+func TestRobotsDirectives(t *testing.T) {
+	tests := []struct {
+		robots            []string
+		header            []string
+		noindex, nofollow bool
+	}{
+		{nil, nil, false, false},
+		{[]string{"noindex"}, nil, true, false},
+		{[]string{"index, NoFollow"}, nil, false, true},
+		{[]string{"none"}, nil, true, true},
+		{nil, []string{"noindex, nofollow"}, true, true},
+		{nil, []string{"googlebot: noindex"}, false, false},
+		{nil, []string{"unavailable_after: 25 Jun 2010 15:00:00 PST", "nofollow"}, false, true},
+		{[]string{"all"}, []string{"noindex"}, true, false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		for _, v := range tt.header {
+			header.Add("X-Robots-Tag", v)
+		}
+		noindex, nofollow := robotsDirectives(&head{robots: tt.robots}, header)
+		assert.Equal(t, tt.noindex, noindex, "%v %v", tt.robots, tt.header)
+		assert.Equal(t, tt.nofollow, nofollow, "%v %v", tt.robots, tt.header)
+	}
+}
+
+func newRobotsTestServer() *httptest.Server {
+	pages := map[string]string{
+		"/":         `<a href="/nofollow">a</a><a href="/header">b</a><a href="/page?utm=1">c</a><a href="/page">d</a>`,
+		"/nofollow": `<meta name="robots" content="noindex, nofollow"><a href="/hidden">hidden</a>`,
+		"/header":   `<a href="/hidden2">hidden</a>`,
+		"/page":     `<link rel="canonical" href="/page">page`,
+		"/hidden":   `hidden`,
+		"/hidden2":  `hidden`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/header" {
+			w.Header().Set("X-Robots-Tag", "nofollow")
+		}
+		fmt.Fprint(w, pages[r.URL.Path])
+		if r.URL.RawQuery != "" {
+			fmt.Fprint(w, " "+r.URL.RawQuery)
+		}
+	}))
+}
+
+func TestCrawlRobotsMeta(t *testing.T) {
+	ts := newRobotsTestServer()
+	defer ts.Close()
+
+	crawl := func(ignore bool) map[string]*CrawlResult {
+		c := NewCrawler(ts.URL, 3)
+		c.SetIgnoreRobotsMeta(ignore)
+		c.SetCanonicalDedup(true)
+		c.SetParallelism(1)
+		var mux sync.Mutex
+		got := map[string]*CrawlResult{}
+		c.OnVisited(func(cr *CrawlResult) {
+			mux.Lock()
+			defer mux.Unlock()
+			got[cr.URL.Path+"?"+cr.URL.RawQuery] = cr
+		})
+		c.Crawl()
+		return got
+	}
+
+	got := crawl(false)
+	assert.Len(t, got, 5)
+	if cr := got["/nofollow?"]; assert.NotNil(t, cr) {
+		assert.True(t, cr.NoIndex)
+		assert.True(t, cr.NoFollow)
+	}
+	if cr := got["/header?"]; assert.NotNil(t, cr) {
+		assert.False(t, cr.NoIndex)
+		assert.True(t, cr.NoFollow)
+	}
+	first, second := got["/page?utm=1"], got["/page?"]
+	if assert.NotNil(t, first) && assert.NotNil(t, second) {
+		assert.Equal(t, ts.URL+"/page", first.Canonical.String())
+		assert.Equal(t, ts.URL+"/page", second.Canonical.String())
+		// The bodies differ, but the canonical URLs are the same.
+		if first.DuplicateOf == nil {
+			assert.Equal(t, first.URL, second.DuplicateOf)
+		} else {
+			assert.Equal(t, second.URL, first.DuplicateOf)
+		}
+	}
+
+	got = crawl(true)
+	assert.Len(t, got, 7)
+}
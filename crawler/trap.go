@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrTrapped is the error thrown if the URL is found in a crawler trap.
+var ErrTrapped = errors.New("Trapped")
+
+// TrapRule is heuristics to detect crawler traps, such as calendars, faceted
+// search and session URLs, which generate endless unique URLs.
+// Zero value of each limit means no limit.
+type TrapRule struct {
+	// MaxPathDepth is the max number of segments of the path.
+	MaxPathDepth int
+	// MaxRepeatedSegments is the max number of occurrences of the same
+	// segment in the path, such as "a" of "/a/b/a/b/a".
+	MaxRepeatedSegments int
+	// MaxQueryParams is the max number of parameters of the query.
+	MaxQueryParams int
+	// MaxURLLength is the max length of the URL.
+	MaxURLLength int
+	// MaxURLsPerHost is the max number of URLs visited on each host.
+	// It counts the same pages as MaxPagesPerHost of Budget, but other
+	// URLs of the host are reported as ErrTrapped.
+	MaxURLsPerHost int
+	// MaxURLsPerPattern is the max number of URLs visited for each
+	// pattern of URLs by URLPattern.
+	MaxURLsPerPattern int
+}
+
+// trapCounts is the number of URLs visited by host and by URL pattern.
+type trapCounts struct {
+	hosts    map[string]int
+	patterns map[string]int
+}
+
+func newTrapCounts() *trapCounts {
+	return &trapCounts{hosts: map[string]int{}, patterns: map[string]int{}}
+}
+
+// check returns ErrTrapped if URL exceeds the limits of its form.
+func (r *TrapRule) check(URL *url.URL) error {
+	segments := pathSegments(URL.Path)
+	if r.MaxPathDepth > 0 && len(segments) > r.MaxPathDepth {
+		return trapped(URL, "path depth %d exceeds %d", len(segments), r.MaxPathDepth)
+	}
+	if r.MaxRepeatedSegments > 0 {
+		counts := map[string]int{}
+		for _, s := range segments {
+			counts[s]++
+			if counts[s] > r.MaxRepeatedSegments {
+				return trapped(URL, "segment %q repeats more than %d times", s, r.MaxRepeatedSegments)
+			}
+		}
+	}
+	if n := len(queryParams(URL.RawQuery)); r.MaxQueryParams > 0 && n > r.MaxQueryParams {
+		return trapped(URL, "%d query parameters exceed %d", n, r.MaxQueryParams)
+	}
+	if n := len(URL.String()); r.MaxURLLength > 0 && n > r.MaxURLLength {
+		return trapped(URL, "URL length %d exceeds %d", n, r.MaxURLLength)
+	}
+	return nil
+}
+
+// count counts URL as visited, and returns ErrTrapped without counting it
+// if its host or pattern has already reached the limit.
+func (r *TrapRule) count(counts *trapCounts, URL *url.URL) error {
+	host := strings.ToLower(URL.Host)
+	if r.MaxURLsPerHost > 0 && counts.hosts[host] >= r.MaxURLsPerHost {
+		return trapped(URL, "host %s reached %d URLs", host, r.MaxURLsPerHost)
+	}
+	pattern := URLPattern(URL)
+	if r.MaxURLsPerPattern > 0 && counts.patterns[pattern] >= r.MaxURLsPerPattern {
+		return trapped(URL, "pattern %s reached %d URLs", pattern, r.MaxURLsPerPattern)
+	}
+	counts.hosts[host]++
+	counts.patterns[pattern]++
+	return nil
+}
+
+func trapped(URL *url.URL, format string, args ...interface{}) error {
+	return fmt.Errorf("%v: %s (%s)", ErrTrapped, URL, fmt.Sprintf(format, args...))
+}
+
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// URLPattern returns the pattern of URL, which is the host and the path
+// with digits replaced by "0", followed by the sorted names of query
+// parameters. For example, "https://example.com/2020/01/?page=3&q=x" is
+// "example.com/0/0/?page&q".
+func URLPattern(URL *url.URL) string {
+	p := strings.ToLower(URL.Host) + digits.ReplaceAllString(URL.EscapedPath(), "0")
+	params := queryParams(URL.RawQuery)
+	if len(params) == 0 {
+		return p
+	}
+	names := make([]string, 0, len(params))
+	seen := map[string]bool{}
+	for _, param := range params {
+		name := strings.SplitN(param, "=", 2)[0]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return p + "?" + strings.Join(names, "&")
+}
+
+func pathSegments(path string) []string {
+	segments := make([]string, 0)
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// queryParams returns the parameters of the raw query, such as "a=1".
+func queryParams(rawQuery string) []string {
+	return strings.FieldsFunc(rawQuery, func(r rune) bool { return r == '&' || r == ';' })
+}
@@ -0,0 +1,130 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrapRuleCheck(t *testing.T) {
+	rule := &TrapRule{
+		MaxPathDepth:        4,
+		MaxRepeatedSegments: 2,
+		MaxQueryParams:      2,
+		MaxURLLength:        60,
+	}
+	testCases := []struct {
+		rawURL string
+		want   bool
+	}{
+		{"https://example.com/", true},
+		{"https://example.com/a/b/c/d", true},
+		{"https://example.com/a/b/c/d/e", false},
+		{"https://example.com/a/b/a/b", true},
+		{"https://example.com/a/b/a/a", false},
+		{"https://example.com/?a=1&b=2", true},
+		{"https://example.com/?a=1&b=2&c=3", false},
+		{"https://example.com/?a=1;b=2;c=3", false},
+		{"https://example.com/" + strings.Repeat("x", 40), true},
+		{"https://example.com/" + strings.Repeat("x", 41), false},
+	}
+
+	for _, tt := range testCases {
+		URL, err := url.Parse(tt.rawURL)
+		assert.NoError(t, err)
+		err = rule.check(URL)
+		assert.Equal(t, tt.want, err == nil, tt.rawURL)
+		if err != nil {
+			assert.True(t, strings.HasPrefix(err.Error(), fmt.Sprintf("%v: %s (", ErrTrapped, tt.rawURL)), err.Error())
+		}
+	}
+
+	var zero TrapRule
+	URL, _ := url.Parse("https://example.com/a/a/a/a/a/a?a=1&b=2&c=3")
+	assert.NoError(t, zero.check(URL))
+}
+
+func TestTrapRuleCount(t *testing.T) {
+	rule := &TrapRule{MaxURLsPerHost: 3, MaxURLsPerPattern: 2}
+	counts := newTrapCounts()
+	testCases := []struct {
+		rawURL string
+		want   bool
+	}{
+		{"https://example.com/item/1", true},
+		{"https://example.com/item/2", true},
+		{"https://example.com/item/3", false},
+		{"https://example.com/about", true},
+		{"https://example.com/contact", false},
+		{"https://other.com/item/1", true},
+	}
+
+	for _, tt := range testCases {
+		URL, err := url.Parse(tt.rawURL)
+		assert.NoError(t, err)
+		err = rule.count(counts, URL)
+		assert.Equal(t, tt.want, err == nil, tt.rawURL)
+	}
+}
+
+func TestURLPattern(t *testing.T) {
+	testCases := []struct {
+		rawURL string
+		want   string
+	}{
+		{"https://example.com/", "example.com/"},
+		{"https://Example.com/2020/01/", "example.com/0/0/"},
+		{"https://example.com/item-123?page=3&q=x", "example.com/item-0?page&q"},
+		{"https://example.com/search?q=x&page=3&q=y", "example.com/search?page&q"},
+		{"https://example.com:8080/a", "example.com:8080/a"},
+	}
+
+	for _, tt := range testCases {
+		URL, err := url.Parse(tt.rawURL)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, URLPattern(URL))
+	}
+}
+
+func TestCrawlTrapRule(t *testing.T) {
+	// The calendar has the link to the next day forever.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		day := 0
+		if strings.HasPrefix(r.URL.Path, "/calendar/") {
+			day, _ = strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/calendar/"))
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<a href="/calendar/%d">next</a>`, day+1)
+	}))
+	defer ts.Close()
+
+	c := NewCrawler(ts.URL, 100)
+	c.SetTrapRule(&TrapRule{MaxURLsPerPattern: 3})
+	var mux sync.Mutex
+	visited := make([]string, 0)
+	trapped := make([]string, 0)
+	c.OnVisited(func(cr *CrawlResult) {
+		mux.Lock()
+		defer mux.Unlock()
+		visited = append(visited, cr.URL.String())
+	})
+	c.OnError(func(err error) {
+		mux.Lock()
+		defer mux.Unlock()
+		trapped = append(trapped, err.Error())
+	})
+	c.Crawl()
+
+	want := []string{ts.URL, ts.URL + "/calendar/1", ts.URL + "/calendar/2", ts.URL + "/calendar/3"}
+	assert.Equal(t, want, visited)
+	if assert.Len(t, trapped, 1) {
+		assert.True(t, strings.HasPrefix(trapped[0], fmt.Sprintf("%v: %s/calendar/4 (", ErrTrapped, ts.URL)), trapped[0])
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/greytabby/grawl/crawler"
+	"github.com/greytabby/grawl/storage"
+)
+
+// runDiff runs `grawl diff <snapshotA> <snapshotB>`.
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	text := fs.Bool("text", false, "Show diff of visible text of changed pages")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: grawl diff [-text] <snapshotA> <snapshotB>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("diff requires two snapshot directories")
+	}
+
+	a, err := openSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := openSnapshot(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	d := storage.DiffSnapshots(a, b)
+	for _, u := range d.Added {
+		fmt.Println("A", u)
+	}
+	for _, u := range d.Removed {
+		fmt.Println("D", u)
+	}
+	for _, u := range d.Changed {
+		fmt.Println("M", u)
+		if *text {
+			if err := printTextDiff(a, b, u); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+	return nil
+}
+
+// openSnapshot opens the snapshot of dir, such as <output_dir>/snapshots/2020-05-01.
+func openSnapshot(dir string) (*storage.Snapshot, error) {
+	baseDir, name, err := storage.SplitSnapshotDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return storage.OpenSnapshot(baseDir, name)
+}
+
+func printTextDiff(a, b *storage.Snapshot, u string) error {
+	ma, mb := a.Pages[u], b.Pages[u]
+	if !crawler.IsHTML(ma.ContentType) || !crawler.IsHTML(mb.ContentType) {
+		return nil
+	}
+	bodyA, err := a.Body(ma)
+	if err != nil {
+		return err
+	}
+	bodyB, err := b.Body(mb)
+	if err != nil {
+		return err
+	}
+	textA, err := storage.VisibleText(bodyA)
+	if err != nil {
+		return err
+	}
+	textB, err := storage.VisibleText(bodyB)
+	if err != nil {
+		return err
+	}
+	for _, line := range storage.DiffLines(textA, textB, false) {
+		fmt.Println("    " + line.String())
+	}
+	return nil
+}
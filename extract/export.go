@@ -0,0 +1,128 @@
+package extract
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// ErrUnknownFormat is the error thrown if export format is unknown.
+var ErrUnknownFormat = errors.New("Unknown export format")
+
+// Record is an extracted record of a page.
+type Record struct {
+	URL  string                 `json:"url"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// Writer writes records. It is safe for concurrent use.
+type Writer interface {
+	Write(*Record) error
+	// Flush writes buffered records to the underlying writer.
+	Flush() error
+}
+
+// NewWriter returns Writer for the format, "jsonl" or "csv".
+// columns are the fields written in CSV.
+func NewWriter(w io.Writer, format string, columns []string) (Writer, error) {
+	switch format {
+	case FormatJSONL:
+		return NewJSONLWriter(w), nil
+	case FormatCSV:
+		return NewCSVWriter(w, columns), nil
+	}
+	return nil, fmt.Errorf("%v: %s", ErrUnknownFormat, format)
+}
+
+// JSONLWriter writes a record as a JSON object per line.
+type JSONLWriter struct {
+	enc *json.Encoder
+	mux sync.Mutex
+}
+
+// NewJSONLWriter returns `*JSONLWriter`.
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{enc: json.NewEncoder(w)}
+}
+
+func (jw *JSONLWriter) Write(r *Record) error {
+	jw.mux.Lock()
+	defer jw.mux.Unlock()
+	return jw.enc.Encode(r)
+}
+
+func (jw *JSONLWriter) Flush() error {
+	return nil
+}
+
+// CSVWriter writes a record as a CSV row of url and columns.
+// Lists and nested records are written as JSON.
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []string
+	header  bool
+	mux     sync.Mutex
+}
+
+// NewCSVWriter returns `*CSVWriter`.
+func NewCSVWriter(w io.Writer, columns []string) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w), columns: columns}
+}
+
+func (cw *CSVWriter) Write(r *Record) error {
+	cw.mux.Lock()
+	defer cw.mux.Unlock()
+	if !cw.header {
+		cw.header = true
+		header := append([]string{"url"}, cw.columns...)
+		if err := cw.w.Write(header); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, 0, len(cw.columns)+1)
+	row = append(row, r.URL)
+	for _, c := range cw.columns {
+		s, err := formatCSVValue(r.Data[c])
+		if err != nil {
+			return err
+		}
+		row = append(row, s)
+	}
+	return cw.w.Write(row)
+}
+
+func (cw *CSVWriter) Flush() error {
+	cw.mux.Lock()
+	defer cw.mux.Unlock()
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func formatCSVValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
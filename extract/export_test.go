@@ -0,0 +1,55 @@
+package extract
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testRecord = &Record{
+	URL: "https://shop.example.com/products/42",
+	Data: map[string]interface{}{
+		"name":     "Blue, Widget",
+		"price":    1234.5,
+		"stock":    int64(12),
+		"released": time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		"images":   []interface{}{"/a.png"},
+		"missing":  nil,
+	},
+}
+
+func TestJSONLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatJSONL, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, w.Write(testRecord))
+	assert.NoError(t, w.Write(&Record{URL: "u", Data: map[string]interface{}{}}))
+	assert.NoError(t, w.Flush())
+
+	expected := `{"url":"https://shop.example.com/products/42","data":{"images":["/a.png"],"missing":null,"name":"Blue, Widget","price":1234.5,"released":"2020-05-01T00:00:00Z","stock":12}}` + "\n" +
+		`{"url":"u","data":{}}` + "\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV, []string{"name", "price", "stock", "released", "images", "missing", "title"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, w.Write(testRecord))
+	assert.NoError(t, w.Flush())
+
+	expected := "url,name,price,stock,released,images,missing,title\n" +
+		`https://shop.example.com/products/42,"Blue, Widget",1234.5,12,2020-05-01T00:00:00Z,"[""/a.png""]",,` + "\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	_, err := NewWriter(&bytes.Buffer{}, "xml", nil)
+	assert.Error(t, err)
+}
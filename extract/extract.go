@@ -0,0 +1,349 @@
+// Package extract extracts structured records from HTML pages by
+// declarative rules.
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v2"
+
+	"github.com/greytabby/grawl/scrape"
+)
+
+// Field types.
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeDate   = "date"
+	TypeList   = "list"
+)
+
+// ErrInvalidConfig is the error thrown if extraction config is invalid.
+var ErrInvalidConfig = errors.New("Invalid extraction config")
+
+// dateLayouts are tried in order when Field.Format is empty.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// Config is a set of extraction rules. It can be written in YAML or JSON.
+//
+//	rules:
+//	  - name: product
+//	    url: '^https://shop\.example\.com/products/'
+//	    fields:
+//	      - name: name
+//	        selector: h1
+//	      - name: price
+//	        selector: .price
+//	        regex: '([0-9.]+)'
+//	        type: float
+//	      - name: images
+//	        selector: img.gallery
+//	        attr: src
+//	        type: list
+//	      - name: variants
+//	        selector: .variant
+//	        type: list
+//	        fields:
+//	          - name: color
+//	            selector: .color
+type Config struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule extracts a record from pages whose URL matches URL regexp.
+type Rule struct {
+	Name   string   `yaml:"name"`
+	URL    string   `yaml:"url"`
+	Fields []*Field `yaml:"fields"`
+
+	re *regexp.Regexp
+}
+
+// Field is a named value of a record.
+type Field struct {
+	Name string `yaml:"name"`
+	// Selector is CSS selector of nodes relative to the page or the parent field.
+	// When both Selector and XPath are empty, the node of the parent is used.
+	Selector string `yaml:"selector"`
+	// XPath is XPath expression of nodes, used instead of Selector.
+	XPath string `yaml:"xpath"`
+	// Attr is the attribute to take value from. By default, text of the node is used.
+	Attr string `yaml:"attr"`
+	// Regex post-processes the value. The first submatch is used if it has
+	// a group, and the whole match if not.
+	Regex string `yaml:"regex"`
+	// Type is one of string, int, float, date and list. Default is string.
+	Type string `yaml:"type"`
+	// Format is the layout of date for `time.Parse`.
+	Format string `yaml:"format"`
+	// Fields makes the field a nested record, or a list of records with type list.
+	Fields []*Field `yaml:"fields"`
+
+	matcher scrape.Matcher
+	xpath   *scrape.XPathExpr
+	re      *regexp.Regexp
+}
+
+// LoadConfig reads YAML or JSON config file.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(b)
+}
+
+// ParseConfig parses YAML or JSON config, and compiles regexps and selectors in it.
+func ParseConfig(b []byte) (*Config, error) {
+	c := new(Config)
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("%v: %s", ErrInvalidConfig, err)
+	}
+	if err := c.Compile(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Compile compiles regexps and selectors of the rules.
+// It must be called before Extract if Config is not made by ParseConfig.
+func (c *Config) Compile() error {
+	for i, r := range c.Rules {
+		if r.Name == "" {
+			r.Name = strconv.Itoa(i)
+		}
+		re, err := regexp.Compile(r.URL)
+		if err != nil {
+			return fmt.Errorf("%v: rule %s: %s", ErrInvalidConfig, r.Name, err)
+		}
+		r.re = re
+		if err := compileFields(r.Fields); err != nil {
+			return fmt.Errorf("%v: rule %s: %s", ErrInvalidConfig, r.Name, err)
+		}
+	}
+	return nil
+}
+
+func compileFields(fields []*Field) error {
+	for _, f := range fields {
+		if f.Name == "" {
+			return errors.New("field without name")
+		}
+		switch f.Type {
+		case "":
+			f.Type = TypeString
+		case TypeString, TypeInt, TypeFloat, TypeDate, TypeList:
+		default:
+			return fmt.Errorf("field %s: unknown type %q", f.Name, f.Type)
+		}
+
+		var err error
+		if f.XPath != "" {
+			if f.xpath, err = scrape.CompileXPath(f.XPath); err != nil {
+				return fmt.Errorf("field %s: %s", f.Name, err)
+			}
+		} else if f.Selector != "" {
+			if f.matcher, err = scrape.CompileSelector(f.Selector); err != nil {
+				return fmt.Errorf("field %s: %s", f.Name, err)
+			}
+		}
+		if f.Regex != "" {
+			if f.re, err = regexp.Compile(f.Regex); err != nil {
+				return fmt.Errorf("field %s: %s", f.Name, err)
+			}
+		}
+		if err := compileFields(f.Fields); err != nil {
+			return fmt.Errorf("field %s: %s", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// Match returns the first rule whose URL regexp matches URL.
+func (c *Config) Match(URL *url.URL) *Rule {
+	s := URL.String()
+	for _, r := range c.Rules {
+		if r.re != nil && r.re.MatchString(s) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Extract extracts a record from the page by the first rule that matches URL.
+// It returns false if no rule matches.
+func (c *Config) Extract(URL *url.URL, root *html.Node) (map[string]interface{}, bool) {
+	r := c.Match(URL)
+	if r == nil {
+		return nil, false
+	}
+	return r.Extract(root), true
+}
+
+// Columns returns names of top-level fields of all rules without duplicates.
+func (c *Config) Columns() []string {
+	seen := map[string]bool{}
+	columns := make([]string, 0)
+	for _, r := range c.Rules {
+		for _, f := range r.Fields {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				columns = append(columns, f.Name)
+			}
+		}
+	}
+	return columns
+}
+
+// Extract extracts a record from the page.
+// A field is nil if no node matches or the value can not be converted.
+func (r *Rule) Extract(root *html.Node) map[string]interface{} {
+	return extractFields(r.Fields, root)
+}
+
+func extractFields(fields []*Field, node *html.Node) map[string]interface{} {
+	record := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		record[f.Name] = f.extract(node)
+	}
+	return record
+}
+
+func (f *Field) extract(node *html.Node) interface{} {
+	nodes := f.find(node)
+	if f.Type == TypeList {
+		values := make([]interface{}, 0, len(nodes))
+		for _, n := range nodes {
+			if len(f.Fields) > 0 {
+				values = append(values, extractFields(f.Fields, n))
+				continue
+			}
+			if v, ok := f.postProcess(f.value(n)); ok {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+
+	if len(nodes) == 0 {
+		return nil
+	}
+	if len(f.Fields) > 0 {
+		return extractFields(f.Fields, nodes[0])
+	}
+	v, ok := f.postProcess(f.value(nodes[0]))
+	if !ok {
+		return nil
+	}
+	return convert(v, f.Type, f.Format)
+}
+
+// find returns nodes selected by the field.
+func (f *Field) find(node *html.Node) []*html.Node {
+	switch {
+	case f.xpath != nil:
+		nodes, err := f.xpath.Nodes(node)
+		if err != nil {
+			// Attribute or string result of XPath, such as `@href`.
+			values := f.xpath.Strings(node)
+			nodes = make([]*html.Node, len(values))
+			for i, v := range values {
+				nodes[i] = &html.Node{Type: html.TextNode, Data: v}
+			}
+		}
+		return nodes
+	case f.matcher != nil:
+		nodes := make([]*html.Node, 0)
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			nodes = append(nodes, scrape.FindAll(c, f.matcher)...)
+		}
+		return nodes
+	}
+	return []*html.Node{node}
+}
+
+func (f *Field) value(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return strings.TrimSpace(n.Data)
+	}
+	if f.Attr != "" {
+		return strings.TrimSpace(scrape.Attr(n, f.Attr))
+	}
+	return scrape.Text(n)
+}
+
+// postProcess applies Regex to s. It returns false if Regex does not match.
+func (f *Field) postProcess(s string) (string, bool) {
+	if f.re == nil {
+		return s, true
+	}
+	m := f.re.FindStringSubmatch(s)
+	switch {
+	case m == nil:
+		return "", false
+	case len(m) > 1:
+		return m[1], true
+	}
+	return m[0], true
+}
+
+// convert converts s to the type. It returns nil if s can not be converted.
+func convert(s, typ, format string) interface{} {
+	switch typ {
+	case TypeInt:
+		i, err := strconv.ParseInt(cleanNumber(s), 10, 64)
+		if err != nil {
+			return nil
+		}
+		return i
+	case TypeFloat:
+		f, err := strconv.ParseFloat(cleanNumber(s), 64)
+		if err != nil {
+			return nil
+		}
+		return f
+	case TypeDate:
+		layouts := dateLayouts
+		if format != "" {
+			layouts = []string{format}
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t
+			}
+		}
+		return nil
+	}
+	return s
+}
+
+// cleanNumber removes spaces and thousands separators.
+func cleanNumber(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ',' || r == ' ' || r == '\u00a0' {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,186 @@
+package extract
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+const testPage = `<html><body>
+<h1> Blue Widget </h1>
+<p class="price">Price: $1,234.50</p>
+<p class="stock">12 in stock</p>
+<span class="sku">SKU-0042</span>
+<time datetime="2020-05-01">May 1, 2020</time>
+<img class="gallery" src="/a.png"><img class="gallery" src="/b.png">
+<ul>
+  <li class="variant"><span class="color">red</span><span class="size">S</span></li>
+  <li class="variant"><span class="color">blue</span><span class="size">M</span></li>
+</ul>
+<div id="seller"><a href="/sellers/1">ACME</a></div>
+</body></html>`
+
+const testConfig = `
+rules:
+  - name: product
+    url: '^https://shop\.example\.com/products/'
+    fields:
+      - name: name
+        selector: h1
+      - name: price
+        selector: .price
+        regex: '\$([0-9.,]+)'
+        type: float
+      - name: stock
+        selector: .stock
+        regex: '[0-9]+'
+        type: int
+      - name: sku
+        xpath: //span[@class="sku"]
+        regex: 'SKU-(\d+)'
+      - name: released
+        selector: time
+        attr: datetime
+        type: date
+      - name: images
+        selector: img.gallery
+        attr: src
+        type: list
+      - name: variants
+        selector: .variant
+        type: list
+        fields:
+          - name: color
+            selector: .color
+          - name: size
+            selector: .size
+      - name: seller
+        selector: '#seller'
+        fields:
+          - name: name
+            selector: a
+          - name: url
+            xpath: a/@href
+      - name: missing
+        selector: .missing
+  - name: other
+    url: '^https://shop\.example\.com/'
+    fields:
+      - name: title
+        selector: h1
+`
+
+func parse(t *testing.T, s string) *html.Node {
+	root, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestExtract(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfig))
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, ok := config.Extract(mustParseURL(t, "https://shop.example.com/products/42"), parse(t, testPage))
+	assert.True(t, ok)
+
+	expected := map[string]interface{}{
+		"name":     "Blue Widget",
+		"price":    1234.5,
+		"stock":    int64(12),
+		"sku":      "0042",
+		"released": time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		"images":   []interface{}{"/a.png", "/b.png"},
+		"variants": []interface{}{
+			map[string]interface{}{"color": "red", "size": "S"},
+			map[string]interface{}{"color": "blue", "size": "M"},
+		},
+		"seller":  map[string]interface{}{"name": "ACME", "url": "/sellers/1"},
+		"missing": nil,
+	}
+	assert.Equal(t, expected, data)
+}
+
+func TestExtractMatch(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfig))
+	if !assert.NoError(t, err) {
+		return
+	}
+	root := parse(t, testPage)
+
+	data, ok := config.Extract(mustParseURL(t, "https://shop.example.com/about"), root)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"title": "Blue Widget"}, data)
+
+	_, ok = config.Extract(mustParseURL(t, "https://example.com/products/42"), root)
+	assert.False(t, ok)
+}
+
+func TestParseConfigJSON(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"rules": [{"url": "/items/", "fields": [
+		{"name": "count", "selector": "p.stock", "type": "int"}]}]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "0", config.Rules[0].Name)
+	data, ok := config.Extract(mustParseURL(t, "http://example.com/items/1"), parse(t, `<p class="stock">7</p>`))
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"count": int64(7)}, data)
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	configs := []string{
+		`rules: [{url: "(", fields: []}]`,
+		`rules: [{url: "", fields: [{name: a, selector: "p["}]}]`,
+		`rules: [{url: "", fields: [{name: a, xpath: "//p["}]}]`,
+		`rules: [{url: "", fields: [{name: a, regex: "("}]}]`,
+		`rules: [{url: "", fields: [{name: a, type: bool}]}]`,
+		`rules: [{url: "", fields: [{selector: p}]}]`,
+		`rules: [{url: "", unknown: 1}]`,
+	}
+	for _, c := range configs {
+		_, err := ParseConfig([]byte(c))
+		assert.Error(t, err, c)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		s, typ, format string
+		expected       interface{}
+	}{
+		{"1 000", TypeInt, "", int64(1000)},
+		{"abc", TypeInt, "", nil},
+		{"-1.5", TypeFloat, "", -1.5},
+		{"01/05/2020", TypeDate, "02/01/2006", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"May 1, 2020", TypeDate, "", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"yesterday", TypeDate, "", nil},
+		{"text", TypeString, "", "text"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, convert(tt.s, tt.typ, tt.format), tt.s)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfig))
+	if !assert.NoError(t, err) {
+		return
+	}
+	expected := []string{"name", "price", "stock", "sku", "released", "images", "variants", "seller", "missing", "title"}
+	assert.Equal(t, expected, config.Columns())
+}
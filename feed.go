@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/net/html"
+
+	"github.com/greytabby/grawl/crawler"
+	"github.com/greytabby/grawl/feed"
+	"github.com/greytabby/grawl/fetcher"
+)
+
+// feedStateFileName is the default state file of feed mode in output_dir.
+const feedStateFileName = "feed_state.json"
+
+// runFeed crawls entries of the feeds of site which are not seen in
+// previous runs. site is a feed, or a page that advertises feeds.
+func runFeed(c *crawler.Crawler) error {
+	path := feedState
+	if path == "" {
+		path = filepath.Join(outputDir, feedStateFileName)
+	}
+	state, err := feed.LoadState(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := newFetcher()
+	if err != nil {
+		return err
+	}
+	feeds, err := discoverFeeds(f, site)
+	if err != nil {
+		return err
+	}
+	if len(feeds) == 0 {
+		return fmt.Errorf("No feed found: %s", site)
+	}
+
+	// entries is new entries by feed URL.
+	entries := map[string][]*feed.Entry{}
+	links := make([]string, 0)
+	// rejected is links which are never crawled by the rules of the crawler.
+	rejected := map[string]bool{}
+	for _, u := range feeds {
+		resp, err := f.FetchResponse(u)
+		if err != nil {
+			logger.Println(err)
+			continue
+		}
+		parsed, err := feed.Parse(resp.Body, resp.URL)
+		if err != nil {
+			logger.Printf("%s: %v", u, err)
+			continue
+		}
+		entries[u] = state.New(u, parsed.Entries)
+		logger.Printf("Feed: %s (%d new of %d entries)", u, len(entries[u]), len(parsed.Entries))
+		for _, e := range entries[u] {
+			if e.Link == "" {
+				continue
+			}
+			if err := c.CheckURL(e.Link); err != nil {
+				logger.Println(err)
+				rejected[e.Link] = true
+				continue
+			}
+			links = append(links, e.Link)
+		}
+	}
+
+	var mux sync.Mutex
+	visited := map[string]bool{}
+	c.OnVisited(func(cr *crawler.CrawlResult) {
+		// Server errors may be temporary.
+		if cr.StatusCode >= http.StatusInternalServerError || cr.StatusCode == http.StatusTooManyRequests {
+			return
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		visited[cr.URL.String()] = true
+	})
+	c.CrawlURLs(links...)
+
+	// Entries not visited, because crawling is stopped or fetching them
+	// failed, are crawled next time. Entries without link or with rejected
+	// link have nothing to crawl.
+	for u, es := range entries {
+		seen := make([]*feed.Entry, 0, len(es))
+		for _, e := range es {
+			if e.Link == "" || rejected[e.Link] || visited[e.Link] {
+				seen = append(seen, e)
+			}
+		}
+		state.Mark(u, seen...)
+	}
+	return state.Save(path)
+}
+
+// discoverFeeds returns URLs of feeds of site. If site is a feed itself,
+// it returns site.
+func discoverFeeds(f *fetcher.DefaultFetcher, site string) ([]string, error) {
+	resp, err := f.FetchResponse(site)
+	if err != nil {
+		return nil, err
+	}
+	_, err = feed.Parse(resp.Body, resp.URL)
+	if err == nil {
+		return []string{site}, nil
+	}
+	if feed.IsFeedType(resp.Header.Get("Content-Type")) {
+		return nil, err
+	}
+
+	root, err := html.Parse(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, err
+	}
+	feeds := make([]string, 0)
+	for _, u := range feed.Discover(root, resp.URL) {
+		feeds = append(feeds, u.String())
+	}
+	return feeds, nil
+}
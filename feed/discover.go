@@ -0,0 +1,52 @@
+package feed
+
+import (
+	"mime"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/greytabby/grawl/scrape"
+)
+
+// Types are the media types of feeds.
+var Types = []string{"application/rss+xml", "application/atom+xml", "application/rdf+xml"}
+
+// IsFeedType reports whether contentType is a media type of feeds.
+func IsFeedType(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, ft := range Types {
+		if t == ft {
+			return true
+		}
+	}
+	return false
+}
+
+// Discover returns URLs of feeds advertised by `<link rel="alternate">` with
+// a feed type in the page, resolved against base. Duplicates are removed.
+func Discover(root *html.Node, base *url.URL) []*url.URL {
+	feeds := make([]*url.URL, 0)
+	seen := map[string]bool{}
+	for _, n := range scrape.FindAll(root, scrape.ByTag(atom.Link)) {
+		if !scrape.HasToken(scrape.Attr(n, "rel"), "alternate") || !IsFeedType(scrape.Attr(n, "type")) {
+			continue
+		}
+		href := strings.TrimSpace(scrape.Attr(n, "href"))
+		if href == "" {
+			continue
+		}
+		u, err := base.Parse(href)
+		if err != nil || seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		feeds = append(feeds, u)
+	}
+	return feeds
+}
@@ -0,0 +1,36 @@
+package feed
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestDiscover(t *testing.T) {
+	page := `<html><head>
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+<link rel="Alternate" type="application/atom+xml; charset=utf-8" href="https://test.com/atom.xml">
+<link rel="alternate" type="application/rss+xml" href="feed.xml">
+<link rel="alternate" hreflang="ja" href="/ja/">
+<link rel="stylesheet" type="application/rss+xml" href="/not-feed.xml">
+</head></html>`
+	root, err := html.Parse(strings.NewReader(page))
+	assert.NoError(t, err)
+	base, _ := url.Parse("https://test.com/")
+	feeds := Discover(root, base)
+	got := make([]string, len(feeds))
+	for i, u := range feeds {
+		got[i] = u.String()
+	}
+	assert.Equal(t, []string{"https://test.com/feed.xml", "https://test.com/atom.xml"}, got)
+}
+
+func TestIsFeedType(t *testing.T) {
+	assert.True(t, IsFeedType("application/rss+xml"))
+	assert.True(t, IsFeedType("application/atom+xml; charset=utf-8"))
+	assert.False(t, IsFeedType("text/html"))
+	assert.False(t, IsFeedType(""))
+}
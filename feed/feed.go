@@ -0,0 +1,248 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ErrNotFeed is the error thrown if the document is not RSS or Atom feed.
+var ErrNotFeed = errors.New("Not a feed")
+
+// Feed is a RSS or Atom feed.
+type Feed struct {
+	Title string `json:"title"`
+	// Link is the URL of the site of the feed.
+	Link    string   `json:"link,omitempty"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Entry is an item of RSS or an entry of Atom.
+type Entry struct {
+	Title string `json:"title"`
+	// Link is the URL of the entry resolved against the URL of the feed.
+	Link string `json:"link"`
+	// Published is the publish date. It is zero if the entry does not have
+	// a valid date. For Atom, updated is used if published is missing.
+	Published time.Time `json:"published,omitempty"`
+	// GUID is guid of RSS or id of Atom.
+	GUID string `json:"guid,omitempty"`
+}
+
+// ID returns the identifier of the entry, GUID or Link if GUID is empty.
+func (e *Entry) ID() string {
+	if e.GUID != "" {
+		return e.GUID
+	}
+	return e.Link
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title string    `xml:"title"`
+	Links []rssLink `xml:"link"`
+	// PubDate is pubDate of RSS 2.0, or dc:date of RSS 1.0.
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"date"`
+	GUID    string `xml:"guid"`
+	About   string `xml:"about,attr"`
+}
+
+type rssChannel struct {
+	Title string    `xml:"title"`
+	Links []rssLink `xml:"link"`
+	Items []rssItem `xml:"item"`
+}
+
+// rss is RSS 2.0, or RSS 1.0 whose items are out of the channel.
+type rss struct {
+	Channel rssChannel `xml:"channel"`
+	Items   []rssItem  `xml:"item"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	ID        string     `xml:"id"`
+}
+
+type atomFeed struct {
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// Parse parses RSS 2.0, RSS 1.0 or Atom document. Links are resolved against base.
+func Parse(body []byte, base *url.URL) (*Feed, error) {
+	root, err := rootElement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Feed{Entries: make([]*Entry, 0)}
+	switch root {
+	case "rss", "RDF":
+		var r rss
+		if err := unmarshal(body, &r); err != nil {
+			return nil, err
+		}
+		f.Title = strings.TrimSpace(r.Channel.Title)
+		f.Link = resolve(base, rssLinkOf(r.Channel.Links))
+		for _, item := range append(r.Channel.Items, r.Items...) {
+			date := item.PubDate
+			if date == "" {
+				date = item.Date
+			}
+			guid := strings.TrimSpace(item.GUID)
+			if guid == "" {
+				guid = strings.TrimSpace(item.About)
+			}
+			f.Entries = append(f.Entries, &Entry{
+				Title:     strings.TrimSpace(item.Title),
+				Link:      resolve(base, rssLinkOf(item.Links)),
+				Published: parseDate(date),
+				GUID:      guid,
+			})
+		}
+	case "feed":
+		var a atomFeed
+		if err := unmarshal(body, &a); err != nil {
+			return nil, err
+		}
+		f.Title = strings.TrimSpace(a.Title)
+		f.Link = resolve(base, atomLinkOf(a.Links))
+		for _, e := range a.Entries {
+			date := e.Published
+			if date == "" {
+				date = e.Updated
+			}
+			f.Entries = append(f.Entries, &Entry{
+				Title:     strings.TrimSpace(e.Title),
+				Link:      resolve(base, atomLinkOf(e.Links)),
+				Published: parseDate(date),
+				GUID:      strings.TrimSpace(e.ID),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("%v: root element is %s", ErrNotFeed, root)
+	}
+	return f, nil
+}
+
+// rootElement returns the local name of the root element.
+func rootElement(body []byte) (string, error) {
+	d := newDecoder(body)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", ErrNotFeed, err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+func unmarshal(body []byte, v interface{}) error {
+	return newDecoder(body).Decode(v)
+}
+
+func newDecoder(body []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+	d.CharsetReader = charsetReader
+	return d
+}
+
+// charsetReader converts ISO-8859-1 and Windows-1252 to UTF-8. Other
+// charsets are read as is.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1":
+		return charmap.ISO8859_1.NewDecoder().Reader(input), nil
+	case "windows-1252", "cp1252":
+		return charmap.Windows1252.NewDecoder().Reader(input), nil
+	}
+	return input, nil
+}
+
+// rssLinkOf returns the link of the channel or item. Atom links in RSS,
+// such as `<atom:link rel="self">`, are skipped.
+func rssLinkOf(links []rssLink) string {
+	for _, l := range links {
+		if l.Href == "" && strings.TrimSpace(l.Text) != "" {
+			return strings.TrimSpace(l.Text)
+		}
+	}
+	return ""
+}
+
+// atomLinkOf returns href of the alternate link.
+// A link without rel is an alternate link.
+func atomLinkOf(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return strings.TrimSpace(l.Href)
+		}
+	}
+	return ""
+}
+
+func resolve(base *url.URL, ref string) string {
+	if ref == "" || base == nil {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// dateFormats is the formats of dates in RFC 822 of RSS with common
+// variations, and RFC 3339 of Atom.
+var dateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate returns the time of s, or zero time if s is not a known format.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, f := range dateFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
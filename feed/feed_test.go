@@ -0,0 +1,91 @@
+package feed
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseFile(t *testing.T, path, base string) *Feed {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	u, _ := url.Parse(base)
+	f, err := Parse(b, u)
+	assert.NoError(t, err)
+	return f
+}
+
+func TestParseRSS(t *testing.T) {
+	f := parseFile(t, "testdata/rss.xml", "https://news.test.com/feed.xml")
+	assert.Equal(t, "News", f.Title)
+	assert.Equal(t, "https://news.test.com/", f.Link)
+	if assert.Len(t, f.Entries, 2) {
+		e := f.Entries[0]
+		assert.Equal(t, "First & foremost", e.Title)
+		assert.Equal(t, "https://news.test.com/posts/1", e.Link)
+		assert.True(t, time.Date(2020, 5, 1, 0, 30, 0, 0, time.UTC).Equal(e.Published))
+		assert.Equal(t, "post-1", e.ID())
+
+		e = f.Entries[1]
+		assert.Equal(t, "Second", e.Title)
+		assert.Equal(t, "https://news.test.com/posts/2", e.Link)
+		assert.True(t, time.Date(2020, 5, 2, 10, 0, 0, 0, time.UTC).Equal(e.Published))
+		assert.Equal(t, "", e.GUID)
+		assert.Equal(t, "https://news.test.com/posts/2", e.ID())
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	f := parseFile(t, "testdata/atom.xml", "https://blog.test.com/atom.xml")
+	assert.Equal(t, "Blog", f.Title)
+	assert.Equal(t, "https://blog.test.com/", f.Link)
+	if assert.Len(t, f.Entries, 2) {
+		e := f.Entries[0]
+		assert.Equal(t, "Hello", e.Title)
+		assert.Equal(t, "https://blog.test.com/hello", e.Link)
+		assert.True(t, time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC).Equal(e.Published))
+		assert.Equal(t, "tag:blog.test.com,2020:hello", e.GUID)
+
+		e = f.Entries[1]
+		assert.Equal(t, "https://blog.test.com/updated", e.Link)
+		assert.True(t, time.Date(2020, 5, 2, 3, 0, 0, 0, time.UTC).Equal(e.Published))
+	}
+}
+
+func TestParseRSS1(t *testing.T) {
+	body := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="https://test.com/"><title>Caf` + "\xe9" + `</title><link>https://test.com/</link></channel>
+<item rdf:about="https://test.com/1"><title>One</title><link>https://test.com/1</link><dc:date>2020-05-01</dc:date></item>
+</rdf:RDF>`
+	f, err := Parse([]byte(body), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Café", f.Title)
+	if assert.Len(t, f.Entries, 1) {
+		assert.Equal(t, "https://test.com/1", f.Entries[0].GUID)
+		assert.True(t, time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC).Equal(f.Entries[0].Published))
+	}
+}
+
+func TestParseWindows1252(t *testing.T) {
+	body := `<?xml version="1.0" encoding="windows-1252"?>
+<rss version="2.0"><channel><title>` + "\x93Price\x94 \x96 10\x80" + `</title>
+<item><title>Caf` + "\xe9" + `</title><link>https://test.com/1</link></item></channel></rss>`
+	f, err := Parse([]byte(body), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "“Price” – 10€", f.Title)
+	if assert.Len(t, f.Entries, 1) {
+		assert.Equal(t, "Café", f.Entries[0].Title)
+	}
+}
+
+func TestParseNotFeed(t *testing.T) {
+	for _, body := range []string{`<html><body>page</body></html>`, `{"a": 1}`, ``} {
+		_, err := Parse([]byte(body), nil)
+		assert.Error(t, err, body)
+	}
+}
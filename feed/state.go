@@ -0,0 +1,102 @@
+package feed
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MaxSeen is the number of entry IDs kept for a feed in State.
+const MaxSeen = 1000
+
+// State is the entries of feeds seen in previous runs.
+type State struct {
+	// Feeds is the state of each feed by its URL.
+	Feeds map[string]*FeedState `json:"feeds"`
+}
+
+// FeedState is the entries of a feed seen in previous runs.
+type FeedState struct {
+	// Seen is IDs of seen entries, the oldest first.
+	Seen []string `json:"seen"`
+	// Checked is the last time when entries are marked.
+	Checked time.Time `json:"checked"`
+}
+
+// NewState returns an empty State.
+func NewState() *State {
+	return &State{Feeds: map[string]*FeedState{}}
+}
+
+// LoadState reads State from the JSON file. If the file does not exist,
+// it returns an empty State.
+func LoadState(path string) (*State, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := NewState()
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if s.Feeds == nil {
+		s.Feeds = map[string]*FeedState{}
+	}
+	return s, nil
+}
+
+// Save writes State to the JSON file. The file is replaced at once,
+// so that the previous state is kept if writing fails.
+func (s *State) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// New returns entries of the feed which are not seen, in the order of entries.
+func (s *State) New(feedURL string, entries []*Entry) []*Entry {
+	seen := map[string]bool{}
+	if fs, ok := s.Feeds[feedURL]; ok {
+		for _, id := range fs.Seen {
+			seen[id] = true
+		}
+	}
+	unseen := make([]*Entry, 0)
+	for _, e := range entries {
+		if id := e.ID(); id != "" && !seen[id] {
+			seen[id] = true
+			unseen = append(unseen, e)
+		}
+	}
+	return unseen
+}
+
+// Mark records entries of the feed as seen. Only the latest MaxSeen IDs are kept.
+func (s *State) Mark(feedURL string, entries ...*Entry) {
+	fs, ok := s.Feeds[feedURL]
+	if !ok {
+		fs = &FeedState{Seen: make([]string, 0)}
+		s.Feeds[feedURL] = fs
+	}
+	for _, e := range entries {
+		fs.Seen = append(fs.Seen, e.ID())
+	}
+	if len(fs.Seen) > MaxSeen {
+		fs.Seen = fs.Seen[len(fs.Seen)-MaxSeen:]
+	}
+	fs.Checked = time.Now()
+}
@@ -0,0 +1,47 @@
+package feed
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+	path := filepath.Join(tempDir, "state", "feed_state.json")
+
+	s, err := LoadState(path)
+	assert.NoError(t, err)
+	entries := []*Entry{
+		{Link: "https://test.com/1", GUID: "1"},
+		{Link: "https://test.com/2"},
+		{Link: "https://test.com/2"},
+		{},
+	}
+	unseen := s.New("https://test.com/feed", entries)
+	assert.Equal(t, entries[:2], unseen)
+	s.Mark("https://test.com/feed", entries[0])
+	assert.NoError(t, s.Save(path))
+
+	s, err = LoadState(path)
+	assert.NoError(t, err)
+	assert.Equal(t, entries[1:2], s.New("https://test.com/feed", entries))
+	assert.Len(t, s.New("https://test.com/other", entries), 2)
+	assert.False(t, s.Feeds["https://test.com/feed"].Checked.IsZero())
+}
+
+func TestStateMaxSeen(t *testing.T) {
+	s := NewState()
+	for i := 0; i < MaxSeen+10; i++ {
+		s.Mark("feed", &Entry{GUID: strconv.Itoa(i)})
+	}
+	seen := s.Feeds["feed"].Seen
+	assert.Len(t, seen, MaxSeen)
+	assert.Equal(t, "10", seen[0])
+}
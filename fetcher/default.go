@@ -1,17 +1,69 @@
 package fetcher
 
 import (
+	"bytes"
 	"io/ioutil"
 	"net/http"
+	"sync"
 )
 
-type DefaultFetcher struct{}
+type DefaultFetcher struct {
+	// Policy restricts the addresses to connect to, including redirects.
+	// It must be set before fetching. By default, all addresses are allowed.
+	Policy *NetworkPolicy
+
+	once   sync.Once
+	client *http.Client
+}
 
 func (df *DefaultFetcher) Fetch(URL string) (body []byte, err error) {
-	resp, err := http.Get(URL)
+	resp, err := df.FetchResponse(URL)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// FetchResponse sends GET request to the URL and returns response
+// with status code, headers and final URL.
+func (df *DefaultFetcher) FetchResponse(URL string) (*Response, error) {
+	resp, err := df.httpClient().Get(URL)
 	if err != nil {
 		return nil, err
 	}
+	return readResponse(resp)
+}
+
+// PostResponse sends POST request with body of the content type to the URL
+// and returns response.
+func (df *DefaultFetcher) PostResponse(URL, contentType string, body []byte) (*Response, error) {
+	resp, err := df.httpClient().Post(URL, contentType, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return readResponse(resp)
+}
+
+func (df *DefaultFetcher) httpClient() *http.Client {
+	df.once.Do(func() {
+		df.client = http.DefaultClient
+		if df.Policy != nil {
+			df.client = df.Policy.client()
+		}
+	})
+	return df.client
+}
+
+func readResponse(resp *http.Response) (*Response, error) {
 	defer resp.Body.Close()
-	return ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		URL:        resp.Request.URL,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
 }
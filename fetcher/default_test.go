@@ -0,0 +1,37 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultFetcherFetchResponse(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	df := new(DefaultFetcher)
+	resp, err := df.FetchResponse(ts.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html", resp.Header.Get("Content-Type"))
+	assert.Equal(t, ts.URL, resp.URL.String())
+	assert.Contains(t, string(resp.Body), "the content")
+}
+
+func TestDefaultFetcherPostResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		fmt.Fprintf(w, "%s %s q=%s", r.Method, r.Header.Get("Content-Type"), r.PostForm.Get("q"))
+	}))
+	defer ts.Close()
+
+	df := new(DefaultFetcher)
+	resp, err := df.PostResponse(ts.URL, "application/x-www-form-urlencoded", []byte("q=grawl"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "POST application/x-www-form-urlencoded q=grawl", string(resp.Body))
+}
@@ -0,0 +1,132 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is the error thrown if the fetcher connects to an
+// address denied by NetworkPolicy.
+var ErrBlockedAddress = errors.New("Blocked address")
+
+// DefaultDenyCIDRs is the private, loopback, link-local (including cloud
+// metadata such as 169.254.169.254), multicast and reserved networks.
+var DefaultDenyCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+// NetworkPolicy restricts the addresses the fetcher connects to.
+// Addresses are checked when connecting, after DNS resolution, so that
+// redirects and DNS rebinding can not reach denied addresses.
+type NetworkPolicy struct {
+	// Deny is the networks which the fetcher does not connect to.
+	Deny []*net.IPNet
+	// Allow is the networks which the fetcher connects to even if they
+	// are in Deny.
+	Allow []*net.IPNet
+}
+
+// NewNetworkPolicy returns the NetworkPolicy which denies DefaultDenyCIDRs.
+func NewNetworkPolicy() *NetworkPolicy {
+	deny, err := ParseCIDRs(DefaultDenyCIDRs...)
+	if err != nil {
+		panic(err)
+	}
+	return &NetworkPolicy{Deny: deny}
+}
+
+// ParseCIDRs parses CIDRs such as "10.0.0.0/8". An IP address without
+// prefix length is a network of the single address.
+func ParseCIDRs(cidrs ...string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid CIDR: %s", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR: %s", s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Check returns ErrBlockedAddress if the fetcher must not connect to ip.
+func (p *NetworkPolicy) Check(ip net.IP) error {
+	for _, n := range p.Allow {
+		if n.Contains(ip) {
+			return nil
+		}
+	}
+	for _, n := range p.Deny {
+		if n.Contains(ip) {
+			return fmt.Errorf("%v: %s", ErrBlockedAddress, ip)
+		}
+	}
+	return nil
+}
+
+// control checks the address of the connection before connecting.
+func (p *NetworkPolicy) control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%v: %s", ErrBlockedAddress, host)
+	}
+	return p.Check(ip)
+}
+
+// client returns the HTTP client whose connections are checked by p.
+// Proxies of environment variables are not used, since the fetcher can
+// not check addresses that proxies connect to.
+func (p *NetworkPolicy) client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.control,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+}
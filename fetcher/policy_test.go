@@ -0,0 +1,106 @@
+package fetcher
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkPolicyCheck(t *testing.T) {
+	testCases := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.32.0.1", true},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+	}
+
+	p := NewNetworkPolicy()
+	for _, tt := range testCases {
+		err := p.Check(net.ParseIP(tt.ip))
+		assert.Equal(t, tt.want, err == nil, tt.ip)
+	}
+
+	allow, err := ParseCIDRs("10.1.0.0/16", "127.0.0.1")
+	assert.NoError(t, err)
+	p.Allow = allow
+	assert.NoError(t, p.Check(net.ParseIP("10.1.2.3")))
+	assert.NoError(t, p.Check(net.ParseIP("127.0.0.1")))
+	assert.Error(t, p.Check(net.ParseIP("10.2.0.1")))
+	assert.Error(t, p.Check(net.ParseIP("127.0.0.2")))
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs("10.0.0.0/8", " ::1 ", "192.0.2.1")
+	assert.NoError(t, err)
+	if assert.Len(t, nets, 3) {
+		assert.Equal(t, "10.0.0.0/8", nets[0].String())
+		assert.Equal(t, "::1/128", nets[1].String())
+		assert.Equal(t, "192.0.2.1/32", nets[2].String())
+	}
+
+	_, err = ParseCIDRs("10.0.0.0/33")
+	assert.EqualError(t, err, "Invalid CIDR: 10.0.0.0/33")
+	_, err = ParseCIDRs("example.com")
+	assert.EqualError(t, err, "Invalid CIDR: example.com")
+}
+
+func TestDefaultFetcherNetworkPolicy(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	df := &DefaultFetcher{Policy: NewNetworkPolicy()}
+	_, err := df.FetchResponse(ts.URL)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ErrBlockedAddress.Error()+": 127.0.0.1")
+	}
+	// Names are checked by the resolved addresses.
+	_, err = df.FetchResponse(strings.Replace(ts.URL, "127.0.0.1", "localhost", 1))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ErrBlockedAddress.Error())
+	}
+	_, err = df.PostResponse(ts.URL, "text/plain", []byte("test"))
+	assert.Error(t, err)
+
+	allowed := NewNetworkPolicy()
+	allowed.Allow, err = ParseCIDRs("127.0.0.1")
+	assert.NoError(t, err)
+	df = &DefaultFetcher{Policy: allowed}
+	resp, err := df.FetchResponse(ts.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestDefaultFetcherNetworkPolicyRedirect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://127.0.0.2:6379/", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	p := NewNetworkPolicy()
+	allow, err := ParseCIDRs("127.0.0.1")
+	assert.NoError(t, err)
+	p.Allow = allow
+	df := &DefaultFetcher{Policy: p}
+	_, err = df.FetchResponse(ts.URL)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ErrBlockedAddress.Error()+": 127.0.0.2")
+	}
+}
@@ -0,0 +1,15 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Response is a fetched resource with its metadata.
+type Response struct {
+	// URL is the final URL after following redirects.
+	URL        *url.URL
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
@@ -6,4 +6,6 @@ require (
 	github.com/chromedp/chromedp v0.5.3
 	github.com/stretchr/testify v1.5.1
 	golang.org/x/net v0.0.0-20200425230154-ff2c4b7c35a0
+	golang.org/x/text v0.3.0
+	gopkg.in/yaml.v2 v2.2.2
 )
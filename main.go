@@ -4,10 +4,18 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/greytabby/grawl/crawler"
+	"github.com/greytabby/grawl/extract"
+	"github.com/greytabby/grawl/fetcher"
 	"github.com/greytabby/grawl/storage"
 )
 
@@ -17,33 +25,115 @@ var (
 )
 
 var (
-	site           string
-	parallelism    int
-	allowedHosts   string
-	depth          int
-	headlessChrome bool
-	outputDir      string
-	v              bool
-	logger         = log.New(os.Stdout, "Grawl ", log.LstdFlags)
+	site            string
+	parallelism     int
+	allowedHosts    string
+	disallowedHosts string
+	allowedURLRe    string
+	disallowedURLRe string
+	allowedSchemes  string
+	stayUnderSeed   bool
+	allowURLs       stringList
+	denyURLs        stringList
+	configFile      string
+	trapRule        crawler.TrapRule
+	budget          crawler.Budget
+	blockPrivate    bool
+	allowCIDRs      string
+	denyCIDRs       string
+	depth           int
+	headlessChrome  bool
+	outputDir       string
+	writeMeta       bool
+	snapshot        bool
+	outputArchive   string
+	outputFormat    string
+	outputTables    string
+	dedup           bool
+	dedupSkipLinks  bool
+	dedupCanonical  bool
+	ignoreRobots    bool
+	skipNoIndex     bool
+	feedMode        bool
+	feedState       string
+	extractMeta     bool
+	extractConfig   string
+	extractOutput   string
+	extractFormat   string
+	submitForms     bool
+	allowPostForms  bool
+	formValues      string
+	followKinds     string
+	skipNofollow    bool
+	v               bool
+	logger          = log.New(os.Stdout, "Grawl ", log.LstdFlags)
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		if err := runDiff(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	flag.BoolVar(&v, "v", false, "show version")
 	flag.StringVar(&site, "site", "", "Site to crawl")
 	flag.StringVar(&outputDir, "output_dir", "", "Directory name for saving crawl result")
+	flag.BoolVar(&writeMeta, "write_meta", false, "Write metadata of each saved page to <file>.meta.json and manifest.jsonl")
+	flag.StringVar(&outputArchive, "output_archive", "", "Archive file (.tar.gz, .tgz or .zip) for saving crawl result instead of output_dir, not with snapshot")
+	flag.StringVar(&outputFormat, "output_format", storage.FormatRaw, "Format of saved pages, raw or markdown (main content of HTML pages)")
+	flag.StringVar(&outputTables, "output_tables", "", "Write tables of each saved page next to it as csv or json")
+	flag.BoolVar(&snapshot, "snapshot", false, "Save crawl result as a dated snapshot of raw pages under output_dir, not with output_archive, output_format, output_tables or write_meta")
 	flag.IntVar(&parallelism, "parallelism", 5, "Number of parallel execution of crawler")
 	flag.IntVar(&depth, "depth", 1, "Limit number of follow links on crawling")
+	flag.BoolVar(&feedMode, "feed", false, "Crawl only entries of the feeds of site which are new since the last run")
+	flag.StringVar(&feedState, "feed_state", "", "State file of entries seen by -feed (default <output_dir>/feed_state.json)")
 	flag.BoolVar(&headlessChrome, "headless_chrome", false, "Use headless chrome on crawling")
+	flag.BoolVar(&dedup, "dedup", false, "Save pages with the same body only once and record others as aliases")
+	flag.BoolVar(&dedupSkipLinks, "dedup_skip_links", false, "Do not follow links of duplicate pages found by -dedup")
+	flag.BoolVar(&dedupCanonical, "dedup_canonical", false, "Record pages with the same canonical URL as aliases of the first one")
+	flag.BoolVar(&ignoreRobots, "ignore_robots_meta", false, "Follow links of pages with nofollow by meta robots or X-Robots-Tag")
+	flag.BoolVar(&skipNoIndex, "skip_noindex", false, "Do not save pages with noindex by meta robots or X-Robots-Tag")
+	flag.BoolVar(&extractMeta, "extract_metadata", false, "Extract JSON-LD, Microdata, RDFa, OpenGraph and Twitter card metadata to meta files")
+	flag.StringVar(&extractConfig, "extract_config", "", "YAML or JSON file of rules to extract records from pages")
+	flag.StringVar(&extractOutput, "extract_output", "", "File for extracted records (default <output_dir>/extracted.<extract_format>)")
+	flag.StringVar(&extractFormat, "extract_format", extract.FormatJSONL, "Format of extracted records, jsonl or csv")
+	flag.StringVar(&followKinds, "follow_kinds", "navigation,frame,redirect", "Kinds of links to follow. Use comma to specify multiple kinds of navigation, stylesheet, image, script, frame, redirect, media, form and other")
+	flag.BoolVar(&skipNofollow, "skip_nofollow", false, "Do not follow links with rel=\"nofollow\"")
+	flag.BoolVar(&submitForms, "submit_forms", false, "Submit GET forms found in pages with their default values and crawl the results")
+	flag.BoolVar(&allowPostForms, "allow_post_forms", false, "Also submit POST forms by -submit_forms")
+	flag.StringVar(&formValues, "form_values", "", "Values used to submit forms instead of defaults, as a query string (e.g. q=test&lang=en)")
 	flag.StringVar(&allowedHosts, "allowed_hosts", "", "Accessibel hosts. Use comma to specify multiple hosts")
-
-	// Load argument from environment variables.
-	flag.VisitAll(func(f *flag.Flag) {
-		if s := os.Getenv(strings.ToUpper(f.Name)); s != "" {
-			f.Value.Set(s)
-		}
-	})
+	flag.StringVar(&disallowedHosts, "disallowed_hosts", "", "Inaccessible hosts, which take precedence over allowed_hosts. Use comma to specify multiple hosts")
+	flag.StringVar(&allowedURLRe, "allowed_url_regex", "", "Regular expression of accessible URLs")
+	flag.StringVar(&disallowedURLRe, "disallowed_url_regex", "", "Regular expression of inaccessible URLs, which takes precedence over allowed_url_regex")
+	flag.StringVar(&allowedSchemes, "allowed_schemes", "", "Accessible URL schemes. Use comma to specify multiple schemes (e.g. https)")
+	flag.BoolVar(&stayUnderSeed, "stay_under_seed", false, "Crawl only URLs under the directory of site")
+	flag.Var(&allowURLs, "allow_url", "Regular expression of accessible URLs. Can be given multiple times")
+	flag.Var(&denyURLs, "deny_url", "Regular expression of inaccessible URLs. Can be given multiple times")
+	flag.IntVar(&trapRule.MaxPathDepth, "max_path_depth", 0, "Skip URLs with more path segments than this as crawler traps (0 means no limit)")
+	flag.IntVar(&trapRule.MaxRepeatedSegments, "max_repeated_segments", 0, "Skip URLs with the same path segment repeated more than this as crawler traps (0 means no limit)")
+	flag.IntVar(&trapRule.MaxQueryParams, "max_query_params", 0, "Skip URLs with more query parameters than this as crawler traps (0 means no limit)")
+	flag.IntVar(&trapRule.MaxURLLength, "max_url_length", 0, "Skip URLs longer than this as crawler traps (0 means no limit)")
+	flag.IntVar(&trapRule.MaxURLsPerHost, "max_urls_per_host", 0, "Skip URLs of a host as a crawler trap after visiting this number of URLs on it (0 means no limit). Unlike max_pages_per_host, hosts are not reported in stats")
+	flag.IntVar(&trapRule.MaxURLsPerPattern, "max_urls_per_pattern", 0, "Skip URLs after visiting this number of URLs of the same pattern, such as /calendar/0/0 (0 means no limit)")
+	flag.IntVar(&budget.MaxPages, "max_pages", 0, "Stop crawling after requesting this number of pages (0 means no limit)")
+	flag.Int64Var(&budget.MaxBytes, "max_bytes", 0, "Stop crawling after fetching this number of bytes of response bodies (0 means no limit)")
+	flag.DurationVar(&budget.MaxDuration, "max_duration", 0, "Stop crawling after this duration such as 10m (0 means no limit)")
+	flag.IntVar(&budget.MaxPagesPerHost, "max_pages_per_host", 0, "Skip pages of a host after requesting this number of pages on it, and report the host in stats (0 means no limit)")
+	flag.BoolVar(&blockPrivate, "block_private_networks", false, "Do not connect to private, loopback, link-local and metadata addresses, including by redirects")
+	flag.StringVar(&allowCIDRs, "allow_cidrs", "", "Networks allowed even with -block_private_networks. Use comma to specify multiple CIDRs")
+	flag.StringVar(&denyCIDRs, "deny_cidrs", "", "Networks not connected to in addition to -block_private_networks. Use comma to specify multiple CIDRs")
+	flag.StringVar(&configFile, "config", "", "YAML file of settings. Flags and environment variables override it")
 
 	flag.Parse()
+	// Load argument from environment variables and config file.
+	if err := loadFlags(flag.CommandLine, configFile); err != nil {
+		logger.Println(err)
+		os.Exit(1)
+	}
 	err := run()
 	if err != nil {
 		os.Exit(1)
@@ -56,30 +146,286 @@ func run() error {
 		return nil
 	}
 	// Initial settings
-	storage := storage.NewFileStorage(outputDir)
+	storage, err := newStorage()
+	if err != nil {
+		logger.Println(err)
+		return err
+	}
+	defer func() {
+		if err := storage.Close(); err != nil {
+			logger.Println(err)
+		}
+	}()
 	lr := crawler.NewLimitRule()
 	if allowedHosts != "" {
 		ah := strings.Split(allowedHosts, ",")
 		lr.AddAllowedHosts(ah...)
 	}
+	if disallowedHosts != "" {
+		lr.AddDisallowedHosts(strings.Split(disallowedHosts, ",")...)
+	}
+	if allowedURLRe != "" {
+		allowURLs = append(allowURLs, allowedURLRe)
+	}
+	for _, expr := range allowURLs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			logger.Println(err)
+			return err
+		}
+		lr.AddAllowedUrls(re)
+	}
+	if disallowedURLRe != "" {
+		denyURLs = append(denyURLs, disallowedURLRe)
+	}
+	for _, expr := range denyURLs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			logger.Println(err)
+			return err
+		}
+		lr.AddDisallowedUrls(re)
+	}
+	if allowedSchemes != "" {
+		lr.AddAllowedSchemes(strings.Split(allowedSchemes, ",")...)
+	}
+	if stayUnderSeed {
+		if err := lr.AddSeedPathPrefix(site); err != nil {
+			logger.Println(err)
+			return err
+		}
+	}
 	c := crawler.NewCrawlerWithLimitRule(site, depth, lr)
+	f, err := newFetcher()
+	if err != nil {
+		logger.Println(err)
+		return err
+	}
 	if headlessChrome {
 		c.UseHeadlessChrome()
+	} else {
+		c.SetFetcher(f)
 	}
 	c.SetParallelism(parallelism)
+	if trapRule != (crawler.TrapRule{}) {
+		c.SetTrapRule(&trapRule)
+	}
+	c.SetBudget(budget)
+	c.SetContentDedup(dedup)
+	c.SetSkipDuplicateLinks(dedupSkipLinks)
+	c.SetCanonicalDedup(dedupCanonical)
+	c.SetIgnoreRobotsMeta(ignoreRobots)
+	c.SetExtractMetadata(extractMeta)
+	kinds, err := crawler.ParseLinkKinds(followKinds)
+	if err != nil {
+		logger.Println(err)
+		return err
+	}
+	c.SetFollowKinds(kinds...)
+	c.SetSkipNofollow(skipNofollow)
+	values, err := url.ParseQuery(formValues)
+	if err != nil {
+		logger.Println(err)
+		return err
+	}
+	c.SetSubmitForms(submitForms)
+	c.SetAllowPostForms(allowPostForms)
+	c.SetFormValues(values)
+	records, closeRecords, err := newRecordWriter(c)
+	if err != nil {
+		logger.Println(err)
+		return err
+	}
+	defer func() {
+		if err := closeRecords(); err != nil {
+			logger.Println(err)
+		}
+	}()
 
 	c.OnVisited(func(cr *crawler.CrawlResult) {
-		logger.Printf("Visited: %s", cr.URL.String())
-		storage.Save(cr)
+		if cr.DuplicateOf != nil {
+			logger.Printf("Visited: %s (duplicate of %s)", cr.URL.String(), cr.DuplicateOf.String())
+		} else {
+			logger.Printf("Visited: %s", cr.URL.String())
+		}
+		if cr.Metadata != nil {
+			for _, w := range cr.Metadata.Warnings {
+				logger.Printf("Metadata warning: %s: %s", cr.URL.String(), w)
+			}
+		}
+		if err := storage.Save(cr); err != nil {
+			logger.Println(err)
+		}
+		if records != nil && cr.Data != nil {
+			r := &extract.Record{URL: cr.URL.String(), Data: cr.Data}
+			if err := records.Write(r); err != nil {
+				logger.Println(err)
+			}
+		}
 	})
 	c.OnError(func(err error) {
 		logger.Println(err)
 	})
 
-	logger.Printf("Output base directory: %s", outputDir)
+	// Stop crawling gracefully, so that storage is finalized.
+	// Second signal kills the process.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		if _, ok := <-sig; ok {
+			logger.Println("Stopping... waiting for pages in flight")
+			signal.Stop(sig)
+			c.Stop()
+		}
+	}()
+
+	if outputArchive != "" {
+		logger.Printf("Output archive: %s", outputArchive)
+	} else {
+		logger.Printf("Output base directory: %s", outputDir)
+	}
 	logger.Printf("Crawling site: %v", site)
 	logger.Printf("Crawling max depth: %v", depth)
 	logger.Println("Start Crawling...")
+	defer logStats(c)
+	if feedMode {
+		if err := runFeed(c); err != nil {
+			logger.Println(err)
+			return err
+		}
+		return nil
+	}
 	c.Crawl()
 	return nil
 }
+
+// newFetcher returns the fetcher with the network policy by flags.
+func newFetcher() (*fetcher.DefaultFetcher, error) {
+	if !blockPrivate && allowCIDRs == "" && denyCIDRs == "" {
+		return new(fetcher.DefaultFetcher), nil
+	}
+	if headlessChrome {
+		return nil, fmt.Errorf("Network policy is not supported with -headless_chrome")
+	}
+	policy := &fetcher.NetworkPolicy{}
+	if blockPrivate {
+		policy = fetcher.NewNetworkPolicy()
+	}
+	if allowCIDRs != "" {
+		nets, err := fetcher.ParseCIDRs(strings.Split(allowCIDRs, ",")...)
+		if err != nil {
+			return nil, err
+		}
+		policy.Allow = nets
+	}
+	if denyCIDRs != "" {
+		nets, err := fetcher.ParseCIDRs(strings.Split(denyCIDRs, ",")...)
+		if err != nil {
+			return nil, err
+		}
+		policy.Deny = append(policy.Deny, nets...)
+	}
+	return &fetcher.DefaultFetcher{Policy: policy}, nil
+}
+
+// logStats logs the summary of crawling and the reason it is stopped.
+func logStats(c *crawler.Crawler) {
+	stats := c.Stats()
+	logger.Printf("Finished: %d pages, %d bytes in %v", stats.Pages, stats.Bytes, stats.Duration.Round(time.Millisecond))
+	switch {
+	case stats.StoppedBy != "":
+		logger.Printf("Stopped by budget: %s", stats.StoppedBy)
+	case c.Stopped():
+		logger.Println("Stopped by signal")
+	}
+	if len(stats.HostsOverBudget) > 0 {
+		logger.Printf("Hosts reached max_pages_per_host: %s", strings.Join(stats.HostsOverBudget, ", "))
+	}
+}
+
+func newStorage() (storage.Storage, error) {
+	if err := storage.CheckOutputFormat(outputFormat); err != nil {
+		return nil, err
+	}
+	if err := storage.CheckTablesFormat(outputTables); err != nil {
+		return nil, err
+	}
+	if snapshot {
+		// Snapshots keep raw bodies only.
+		conflicts := []struct {
+			name string
+			set  bool
+		}{
+			{"output_archive", outputArchive != ""},
+			{"output_format", outputFormat != storage.FormatRaw},
+			{"output_tables", outputTables != ""},
+			{"write_meta", writeMeta},
+		}
+		for _, f := range conflicts {
+			if f.set {
+				return nil, fmt.Errorf("-%s can not be used with -snapshot", f.name)
+			}
+		}
+	}
+	if outputArchive != "" {
+		as, err := storage.CreateArchiveStorage(outputArchive)
+		if err != nil {
+			return nil, err
+		}
+		as.WriteMeta = writeMeta
+		as.OutputFormat = outputFormat
+		as.Tables = outputTables
+		as.SkipNoIndex = skipNoIndex
+		return as, nil
+	}
+	if snapshot {
+		ss := storage.NewSnapshotStorage(outputDir)
+		ss.SkipNoIndex = skipNoIndex
+		return ss, nil
+	}
+	fs := storage.NewFileStorage(outputDir)
+	fs.WriteMeta = writeMeta
+	fs.OutputFormat = outputFormat
+	fs.Tables = outputTables
+	fs.SkipNoIndex = skipNoIndex
+	return fs, nil
+}
+
+// newRecordWriter sets the extractor of extract_config to c, and returns
+// the writer of extracted records. The writer is nil without extract_config.
+func newRecordWriter(c *crawler.Crawler) (extract.Writer, func() error, error) {
+	nop := func() error { return nil }
+	if extractConfig == "" {
+		return nil, nop, nil
+	}
+	config, err := extract.LoadConfig(extractConfig)
+	if err != nil {
+		return nil, nop, err
+	}
+	path := extractOutput
+	if path == "" {
+		path = filepath.Join(outputDir, "extracted."+extractFormat)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nop, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nop, err
+	}
+	w, err := extract.NewWriter(f, extractFormat, config.Columns())
+	if err != nil {
+		f.Close()
+		return nil, nop, err
+	}
+	c.SetExtractor(config)
+	logger.Printf("Extracted records: %s", path)
+	return w, func() error {
+		err := w.Flush()
+		if e := f.Close(); err == nil {
+			err = e
+		}
+		return err
+	}, nil
+}
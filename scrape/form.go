@@ -0,0 +1,267 @@
+package scrape
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Form is a form element with its fields.
+type Form struct {
+	// Action is the action attribute as written. Empty action submits
+	// to the URL of the document.
+	Action string `json:"action"`
+	// Method is "GET", "POST" or "DIALOG". Default is "GET".
+	Method string `json:"method"`
+	// Enctype is the encoding of POST body.
+	// Default is "application/x-www-form-urlencoded".
+	Enctype string       `json:"enctype"`
+	ID      string       `json:"id,omitempty"`
+	Name    string       `json:"name,omitempty"`
+	Fields  []*FormField `json:"fields"`
+	// Node is the form element.
+	Node *html.Node `json:"-"`
+}
+
+// FormField is a control of a form.
+type FormField struct {
+	Name string `json:"name"`
+	// Type is the type of input, or "select", "textarea" for them.
+	// Type of button is "submit", "reset" or "button".
+	Type string `json:"type"`
+	// Value is the default value. For select, it is the value of the
+	// first selected option.
+	Value    string       `json:"value"`
+	Checked  bool         `json:"checked,omitempty"`
+	Multiple bool         `json:"multiple,omitempty"`
+	Required bool         `json:"required,omitempty"`
+	Disabled bool         `json:"disabled,omitempty"`
+	Options  []FormOption `json:"options,omitempty"`
+	// Node is the element of the control.
+	Node *html.Node `json:"-"`
+}
+
+// FormOption is an option of select.
+type FormOption struct {
+	Value    string `json:"value"`
+	Label    string `json:"label"`
+	Selected bool   `json:"selected,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// Form encodings.
+const (
+	EnctypeURLEncoded = "application/x-www-form-urlencoded"
+	EnctypeMultipart  = "multipart/form-data"
+	EnctypeTextPlain  = "text/plain"
+)
+
+// Forms returns every form in node with its fields. Controls outside the
+// form associated by form attribute are included in document order.
+func Forms(node *html.Node) []*Form {
+	root := node
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	controls := map[*html.Node][]*html.Node{}
+	for _, c := range FindAll(root, isFormControl) {
+		if owner := formOwner(root, c); owner != nil {
+			controls[owner] = append(controls[owner], c)
+		}
+	}
+
+	forms := make([]*Form, 0)
+	for _, n := range FindAll(node, ByTag(atom.Form)) {
+		f := &Form{
+			Action:  strings.TrimSpace(Attr(n, "action")),
+			Method:  formMethod(Attr(n, "method")),
+			Enctype: formEnctype(Attr(n, "enctype")),
+			ID:      Attr(n, "id"),
+			Name:    Attr(n, "name"),
+			Fields:  make([]*FormField, 0),
+			Node:    n,
+		}
+		for _, c := range controls[n] {
+			f.Fields = append(f.Fields, newFormField(c))
+		}
+		forms = append(forms, f)
+	}
+	return forms
+}
+
+// Field returns the first field with name, or nil if there is not.
+func (f *Form) Field(name string) *FormField {
+	for _, field := range f.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// Values returns the values submitted by the form without changes,
+// by the rules of HTML form submission. Disabled fields, unchecked
+// checkboxes and radio buttons, buttons and file inputs are skipped.
+func (f *Form) Values() url.Values {
+	values := url.Values{}
+	for _, field := range f.Fields {
+		if field.Name == "" || field.Disabled {
+			continue
+		}
+		switch field.Type {
+		case "submit", "reset", "button", "image", "file":
+			continue
+		case "checkbox", "radio":
+			if field.Checked {
+				values.Add(field.Name, field.Value)
+			}
+		case "select":
+			selected := false
+			for _, o := range field.Options {
+				if o.Selected && !o.Disabled {
+					values.Add(field.Name, o.Value)
+					selected = true
+				}
+			}
+			if !selected && !field.Multiple {
+				for _, o := range field.Options {
+					if !o.Disabled {
+						values.Add(field.Name, o.Value)
+						break
+					}
+				}
+			}
+		default:
+			values.Add(field.Name, field.Value)
+		}
+	}
+	return values
+}
+
+func isFormControl(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Input, atom.Select, atom.Textarea, atom.Button:
+		return true
+	}
+	return false
+}
+
+// formOwner returns the form element that the control belongs to.
+func formOwner(root, n *html.Node) *html.Node {
+	if id, ok := attr(n, "form"); ok {
+		return Find(root, And(ByTag(atom.Form), ByID(id)))
+	}
+	return Closest(n, ByTag(atom.Form))
+}
+
+func formMethod(s string) string {
+	switch m := strings.ToUpper(strings.TrimSpace(s)); m {
+	case "POST", "DIALOG":
+		return m
+	}
+	return "GET"
+}
+
+func formEnctype(s string) string {
+	switch e := strings.ToLower(strings.TrimSpace(s)); e {
+	case EnctypeMultipart, EnctypeTextPlain:
+		return e
+	}
+	return EnctypeURLEncoded
+}
+
+func newFormField(n *html.Node) *FormField {
+	_, required := attr(n, "required")
+	_, multiple := attr(n, "multiple")
+	field := &FormField{
+		Name:     Attr(n, "name"),
+		Required: required,
+		Multiple: multiple,
+		Disabled: isDisabledControl(n),
+		Node:     n,
+	}
+
+	switch n.DataAtom {
+	case atom.Input:
+		field.Type = strings.ToLower(strings.TrimSpace(Attr(n, "type")))
+		if field.Type == "" {
+			field.Type = "text"
+		}
+		value, ok := attr(n, "value")
+		if !ok && (field.Type == "checkbox" || field.Type == "radio") {
+			value = "on"
+		}
+		field.Value = value
+		_, field.Checked = attr(n, "checked")
+	case atom.Textarea:
+		field.Type = "textarea"
+		var b strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			b.WriteString(c.Data)
+		}
+		field.Value = strings.TrimPrefix(b.String(), "\n")
+	case atom.Select:
+		field.Type = "select"
+		for _, o := range FindAll(n, ByTag(atom.Option)) {
+			option := FormOption{Label: Text(o)}
+			if v, ok := attr(o, "value"); ok {
+				option.Value = v
+			} else {
+				option.Value = option.Label
+			}
+			_, option.Selected = attr(o, "selected")
+			_, option.Disabled = attr(o, "disabled")
+			if g := Closest(o, ByTag(atom.Optgroup)); g != nil && isDisabled(g) {
+				option.Disabled = true
+			}
+			field.Options = append(field.Options, option)
+		}
+		for _, o := range field.Options {
+			if o.Selected {
+				field.Value = o.Value
+				break
+			}
+		}
+		if field.Value == "" && !field.Multiple && len(field.Options) > 0 {
+			field.Value = field.Options[0].Value
+		}
+	case atom.Button:
+		field.Type = strings.ToLower(strings.TrimSpace(Attr(n, "type")))
+		if field.Type != "reset" && field.Type != "button" {
+			field.Type = "submit"
+		}
+		field.Value = Attr(n, "value")
+	}
+	return field
+}
+
+// isDisabledControl reports whether the control is disabled by itself or
+// by a disabled fieldset, except controls in the first legend of it.
+func isDisabledControl(n *html.Node) bool {
+	if isDisabled(n) {
+		return true
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.DataAtom != atom.Fieldset || !isDisabled(p) {
+			continue
+		}
+		legends := Children(p, ByTag(atom.Legend))
+		if len(legends) > 0 && isAncestor(legends[0], n) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// isAncestor reports whether a is n or an ancestor of n.
+func isAncestor(a, n *html.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n == a {
+			return true
+		}
+	}
+	return false
+}
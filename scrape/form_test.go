@@ -0,0 +1,93 @@
+package scrape
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+const testFormPage = `<html><body>
+<form id="search" action="/search">
+  <input name="q" placeholder="Search">
+  <input type="hidden" name="lang" value="en">
+  <input type="checkbox" name="exact">
+  <input type="checkbox" name="safe" checked>
+  <input type="radio" name="sort" value="new">
+  <input type="radio" name="sort" value="old" checked>
+  <select name="category">
+    <option value="">All</option>
+    <option value="books" selected>Books</option>
+  </select>
+  <select name="tags" multiple>
+    <option>a</option>
+    <optgroup label="disabled" disabled><option selected>b</option></optgroup>
+  </select>
+  <textarea name="note">
+hello</textarea>
+  <input name="disabled" value="x" disabled>
+  <fieldset disabled>
+    <legend><input name="in_legend" value="y"></legend>
+    <input name="in_fieldset" value="z">
+  </fieldset>
+  <input type="file" name="upload">
+  <button name="go" value="1">Go</button>
+</form>
+<input name="outside" value="o" form="search">
+<form method="post" enctype="multipart/form-data" action="upload.php"><input name="a"></form>
+<form method="PUT"><select name="empty"></select></form>
+</body></html>`
+
+func TestForms(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(testFormPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forms := Forms(root)
+	if !assert.Len(t, forms, 3) {
+		return
+	}
+
+	search := forms[0]
+	assert.Equal(t, "/search", search.Action)
+	assert.Equal(t, "GET", search.Method)
+	assert.Equal(t, EnctypeURLEncoded, search.Enctype)
+	assert.Equal(t, "search", search.ID)
+	names := make([]string, len(search.Fields))
+	for i, f := range search.Fields {
+		names[i] = f.Name
+	}
+	assert.Equal(t, []string{"q", "lang", "exact", "safe", "sort", "sort", "category", "tags",
+		"note", "disabled", "in_legend", "in_fieldset", "upload", "go", "outside"}, names)
+
+	category := search.Field("category")
+	if assert.NotNil(t, category) {
+		assert.Equal(t, "select", category.Type)
+		assert.Equal(t, "books", category.Value)
+		assert.Equal(t, []FormOption{{Value: "", Label: "All"}, {Value: "books", Label: "Books", Selected: true}}, category.Options)
+	}
+	assert.Equal(t, "submit", search.Field("go").Type)
+	assert.True(t, search.Field("in_fieldset").Disabled)
+	assert.False(t, search.Field("in_legend").Disabled)
+	assert.Nil(t, search.Field("nothing"))
+
+	expected := url.Values{
+		"q":         {""},
+		"lang":      {"en"},
+		"safe":      {"on"},
+		"sort":      {"old"},
+		"category":  {"books"},
+		"note":      {"hello"},
+		"in_legend": {"y"},
+		"outside":   {"o"},
+	}
+	assert.Equal(t, expected, search.Values())
+
+	assert.Equal(t, "POST", forms[1].Method)
+	assert.Equal(t, EnctypeMultipart, forms[1].Enctype)
+	assert.Equal(t, "upload.php", forms[1].Action)
+	assert.Equal(t, "GET", forms[2].Method)
+	assert.Equal(t, url.Values{}, forms[2].Values())
+}
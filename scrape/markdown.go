@@ -0,0 +1,340 @@
+package scrape
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Markdown converts node and its descendants to Markdown.
+// Scripts, styles and hidden elements are skipped.
+// Relative URLs of links and images are resolved against base if it is not nil.
+func Markdown(node *html.Node, base *url.URL) string {
+	mc := &markdownConverter{base: base, skip: isNonContent}
+	return mc.convert(node)
+}
+
+type markdownConverter struct {
+	base *url.URL
+	// skip reports whether the element is not converted.
+	skip func(*html.Node) bool
+	// skipTitle is the text of h1 which is already written as the title.
+	skipTitle string
+}
+
+func (mc *markdownConverter) convert(node *html.Node) string {
+	var blocks []string
+	if node.Type == html.ElementNode && !mc.skip(node) {
+		blocks = mc.block(node)
+	} else {
+		blocks = mc.blocks(node)
+	}
+	s := strings.Join(blocks, "\n\n")
+	if s == "" {
+		return ""
+	}
+	return s + "\n"
+}
+
+// isNonContent reports whether the element never has visible content.
+func isNonContent(n *html.Node) bool {
+	return isElement(n) && (n.DataAtom == atom.Head || isInvisible(n))
+}
+
+// blocks converts children of n, and returns Markdown blocks.
+func (mc *markdownConverter) blocks(n *html.Node) []string {
+	out := make([]string, 0)
+	var inline strings.Builder
+	flush := func() {
+		if s := cleanInline(inline.String()); s != "" {
+			out = append(out, s)
+		}
+		inline.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if mc.skip(c) {
+			continue
+		}
+		if isElement(c) && isBlock(c) {
+			flush()
+			out = append(out, mc.block(c)...)
+			continue
+		}
+		inline.WriteString(mc.inline(c))
+	}
+	flush()
+	return out
+}
+
+// block converts a block element.
+func (mc *markdownConverter) block(n *html.Node) []string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		text := cleanInline(mc.inlineChildren(n))
+		if text == "" || level == 1 && mc.skipTitle != "" && Text(n) == mc.skipTitle {
+			return nil
+		}
+		return []string{strings.Repeat("#", level) + " " + strings.Replace(text, "  \n", " ", -1)}
+	case atom.Pre:
+		return []string{mc.pre(n)}
+	case atom.Blockquote:
+		inner := strings.Join(mc.blocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		lines := strings.Split(inner, "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimRight("> "+l, " ")
+		}
+		return []string{strings.Join(lines, "\n")}
+	case atom.Ul, atom.Ol:
+		if s := mc.list(n); s != "" {
+			return []string{s}
+		}
+		return nil
+	case atom.Hr:
+		return []string{"---"}
+	case atom.Table:
+		if s := mc.table(n); s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+	return mc.blocks(n)
+}
+
+func (mc *markdownConverter) pre(n *html.Node) string {
+	lang := ""
+	if code := Find(n, ByTag(atom.Code)); code != nil {
+		for _, c := range strings.Fields(Attr(code, "class")) {
+			if strings.HasPrefix(c, "language-") {
+				lang = strings.TrimPrefix(c, "language-")
+				break
+			}
+		}
+	}
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		if n.DataAtom == atom.Br {
+			b.WriteByte('\n')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	code := strings.TrimRight(strings.TrimPrefix(b.String(), "\n"), "\n ")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+func (mc *markdownConverter) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	num := 1
+	if s, err := strconv.Atoi(Attr(n, "start")); err == nil && ordered {
+		num = s
+	}
+	items := make([]string, 0)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.DataAtom != atom.Li || mc.skip(c) {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		lines := strings.Split(strings.Join(mc.blocks(c), "\n"), "\n")
+		indent := strings.Repeat(" ", len(marker))
+		for i := range lines {
+			if i == 0 {
+				lines[i] = marker + lines[i]
+			} else if lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, strings.TrimRight(strings.Join(lines, "\n"), " "))
+	}
+	return strings.Join(items, "\n")
+}
+
+// table converts table to GFM table. The first row is used as header.
+func (mc *markdownConverter) table(n *html.Node) string {
+	rows := make([][]string, 0)
+	for _, tr := range FindAll(n, ByTag(atom.Tr)) {
+		// Rows of nested tables belong to them.
+		if Closest(Parent(tr), ByTag(atom.Table)) != n {
+			continue
+		}
+		row := make([]string, 0)
+		for _, cell := range Children(tr, Or(ByTag(atom.Td), ByTag(atom.Th))) {
+			text := cleanInline(mc.inlineChildren(cell))
+			text = strings.Replace(text, "  \n", " ", -1)
+			row = append(row, strings.Replace(text, "|", `\|`, -1))
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	width := 0
+	for _, r := range rows {
+		if len(r) > width {
+			width = len(r)
+		}
+	}
+	lines := make([]string, 0, len(rows)+1)
+	for i, r := range rows {
+		for len(r) < width {
+			r = append(r, "")
+		}
+		lines = append(lines, "| "+strings.Join(r, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", width))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inline converts an inline node.
+func (mc *markdownConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeMarkdown(collapseSpace(n.Data))
+	case html.ElementNode:
+	default:
+		return mc.inlineChildren(n)
+	}
+	if mc.skip(n) {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "  \n"
+	case atom.Strong, atom.B:
+		return wrapInline("**", mc.inlineChildren(n))
+	case atom.Em, atom.I:
+		return wrapInline("_", mc.inlineChildren(n))
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline("~~", mc.inlineChildren(n))
+	case atom.Code, atom.Kbd, atom.Samp:
+		code := collapseSpace(Text(n))
+		if code == "" {
+			return ""
+		}
+		fence := "`"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+			code = " " + code + " "
+		}
+		return fence + code + fence
+	case atom.A:
+		text := mc.inlineChildren(n)
+		href := strings.TrimSpace(Attr(n, "href"))
+		if href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") || strings.TrimSpace(text) == "" {
+			return text
+		}
+		return wrapInline("", "["+strings.TrimSpace(text)+"]("+mc.url(href)+")")
+	case atom.Img:
+		src := Attr(n, "src")
+		if src == "" {
+			return ""
+		}
+		return "![" + escapeMarkdown(collapseSpace(Attr(n, "alt"))) + "](" + mc.url(src) + ")"
+	}
+	return mc.inlineChildren(n)
+}
+
+func (mc *markdownConverter) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(mc.inline(c))
+	}
+	return b.String()
+}
+
+// url resolves ref against base, and escapes characters that end the
+// link destination.
+func (mc *markdownConverter) url(ref string) string {
+	if mc.base != nil {
+		if u, err := mc.base.Parse(ref); err == nil {
+			ref = u.String()
+		}
+	}
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(ref)
+}
+
+// wrapInline wraps s with marker, keeping surrounding spaces outside.
+func wrapInline(marker, s string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	lead := s[:strings.Index(s, trimmed)]
+	trail := s[len(lead)+len(trimmed):]
+	return lead + marker + trimmed + marker + trail
+}
+
+// collapseSpace replaces runs of white space with a single space.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// cleanInline trims lines of converted inline content and
+// removes spaces doubled at boundaries of elements.
+func cleanInline(s string) string {
+	lines := strings.Split(s, "  \n")
+	out := make([]string, 0, len(lines))
+	for _, l := range lines {
+		l = strings.TrimSpace(collapseSpace(l))
+		out = append(out, l)
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	for len(out) > 0 && out[0] == "" {
+		out = out[1:]
+	}
+	return strings.Join(out, "  \n")
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+)
+
+// escapeMarkdown escapes characters that have meaning in inline Markdown.
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
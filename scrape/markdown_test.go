@@ -0,0 +1,81 @@
+package scrape
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestMarkdown(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(`<html><head><title>ignored</title></head><body>
+<h1>Title  with <em>emphasis</em></h1>
+<p>Some <strong>bold </strong>text, a <a href="/page?a=1">link</a> and <code>x := 1</code>.<br>
+Next line with * and _ escaped.</p>
+<script>alert(1)</script>
+<p style="display: none">hidden</p>
+<ul>
+  <li>one</li>
+  <li>two
+    <ol start="3"><li>three</li><li><p>four</p></li></ol>
+  </li>
+</ul>
+<blockquote><p>quote</p><p>more</p></blockquote>
+<pre><code class="language-go">func main() {
+	fmt.Println("hi")
+}
+</code></pre>
+<hr>
+<table>
+  <thead><tr><th>Name</th><th>Price</th></tr></thead>
+  <tbody><tr><td>A | B</td><td>1</td></tr><tr><td>C</td></tr></tbody>
+</table>
+<p><img src="img/a b.png" alt="An image"></p>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/dir/")
+
+	expected := "# Title with _emphasis_\n\n" +
+		"Some **bold** text, a [link](https://example.com/page?a=1) and `x := 1`.  \n" +
+		"Next line with \\* and \\_ escaped.\n\n" +
+		"- one\n" +
+		"- two\n" +
+		"  3. three\n" +
+		"  4. four\n\n" +
+		"> quote\n" +
+		">\n" +
+		"> more\n\n" +
+		"```go\n" +
+		"func main() {\n" +
+		"\tfmt.Println(\"hi\")\n" +
+		"}\n" +
+		"```\n\n" +
+		"---\n\n" +
+		"| Name | Price |\n" +
+		"| --- | --- |\n" +
+		"| A \\| B | 1 |\n" +
+		"| C |  |\n\n" +
+		"![An image](https://example.com/dir/img/a%20b.png)\n"
+	assert.Equal(t, expected, Markdown(root, base))
+}
+
+func TestArticleMarkdown(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(testArticlePage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/posts/coffee")
+
+	expected := "# How to brew coffee\n\n" +
+		"_Jane Doe · 2020-05-01_\n\n" +
+		"By Jane Doe\n\n" +
+		"![A cup](https://example.com/images/coffee.jpg)\n\n" +
+		"Brewing coffee at home is easy, cheap, and rewarding, once you know the basics of extraction.\n\n" +
+		"Start with fresh beans, grind them just before brewing, and use water slightly below boiling.\n\n" +
+		"Finally, experiment with the ratio of coffee to water, and keep notes of what you like.\n"
+	assert.Equal(t, expected, ExtractArticle(root).Markdown(base))
+}
@@ -0,0 +1,101 @@
+package scrape
+
+import (
+	"regexp"
+
+	"golang.org/x/net/html"
+)
+
+// ByID return `Matcher` that match node that have specified id.
+func ByID(id string) Matcher {
+	return func(node *html.Node) bool {
+		return node.Type == html.ElementNode && Attr(node, "id") == id
+	}
+}
+
+// ByClass return `Matcher` that match node that have specified class
+// in its class list.
+func ByClass(class string) Matcher {
+	return func(node *html.Node) bool {
+		return node.Type == html.ElementNode && includesWord(Attr(node, "class"), class)
+	}
+}
+
+// ByAttr return `Matcher` that match node that have attribute with specified value.
+func ByAttr(key, val string) Matcher {
+	return func(node *html.Node) bool {
+		v, ok := attr(node, key)
+		return ok && v == val
+	}
+}
+
+// ByAttrRegexp return `Matcher` that match node that have attribute
+// whose value matches re.
+func ByAttrRegexp(key string, re *regexp.Regexp) Matcher {
+	return func(node *html.Node) bool {
+		v, ok := attr(node, key)
+		return ok && re.MatchString(v)
+	}
+}
+
+// ByText return `Matcher` that match element whose `Text` equals text.
+func ByText(text string) Matcher {
+	return func(node *html.Node) bool {
+		return node.Type == html.ElementNode && Text(node) == text
+	}
+}
+
+// HasChild return `Matcher` that match node that have a child matching m.
+func HasChild(m Matcher) Matcher {
+	return func(node *html.Node) bool {
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			if m(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasAncestor return `Matcher` that match node that have an ancestor matching m.
+func HasAncestor(m Matcher) Matcher {
+	return func(node *html.Node) bool {
+		for a := node.Parent; a != nil; a = a.Parent {
+			if m(a) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// And return `Matcher` that match node matching all of matchers.
+func And(matchers ...Matcher) Matcher {
+	return func(node *html.Node) bool {
+		for _, m := range matchers {
+			if !m(node) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or return `Matcher` that match node matching any of matchers.
+func Or(matchers ...Matcher) Matcher {
+	return func(node *html.Node) bool {
+		for _, m := range matchers {
+			if m(node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not return `Matcher` that match node not matching m.
+func Not(m Matcher) Matcher {
+	return func(node *html.Node) bool {
+		return !m(node)
+	}
+}
@@ -0,0 +1,76 @@
+package scrape
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func TestMatchers(t *testing.T) {
+	node, err := html.Parse(strings.NewReader(testHTML))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		matcher Matcher
+		want    int
+	}{
+		{"ByID", ByID("bc"), 1},
+		{"ByClass", ByClass("class1"), 1},
+		{"ByClass partial", ByClass("class"), 0},
+		{"ByAttr", ByAttr("href", ""), 1},
+		{"ByAttrRegexp", ByAttrRegexp("href", regexp.MustCompile(`^(https?|ftp)://`)), 2},
+		{"ByText", ByText("textC"), 2}, // li and ul
+		{"HasChild", And(ByTag(atom.Div), HasChild(ByTag(atom.P))), 1},
+		{"HasAncestor", And(ByTag(atom.A), HasAncestor(ByID("b"))), 5},
+		{"And", And(ByTag(atom.A), HasAncestor(ByID("bc"))), 1},
+		{"Or", Or(ByTag(atom.Span), ByTag(atom.Ul)), 2},
+		{"Not", And(ByTag(atom.Div), Not(ByID("a"))), 2},
+	}
+	for _, tt := range testCases {
+		got := FindAll(node, tt.matcher)
+		assert.Equal(t, tt.want, len(got), tt.name)
+	}
+}
+
+func TestFind(t *testing.T) {
+	node, err := html.Parse(strings.NewReader(testHTML))
+	assert.NoError(t, err)
+
+	visited := 0
+	got := Find(node, func(n *html.Node) bool {
+		visited++
+		return n.DataAtom == atom.A
+	})
+	assert.Equal(t, "#jump", Attr(got, "href"))
+	assert.True(t, visited < len(FindAll(node, func(*html.Node) bool { return true })))
+
+	assert.Nil(t, Find(node, ByTag(atom.Table)))
+}
+
+func TestTraversal(t *testing.T) {
+	node, err := html.Parse(strings.NewReader(testHTML))
+	assert.NoError(t, err)
+
+	a := Find(node, ByAttr("href", "https://abs.test.link"))
+	assert.Equal(t, "bc", Attr(Closest(a, ByTag(atom.Div)), "id"))
+	assert.Equal(t, a, Closest(a, ByTag(atom.A)))
+	assert.Nil(t, Closest(a, ByTag(atom.Table)))
+	assert.Equal(t, "bc", Attr(Parent(a), "id"))
+	assert.Nil(t, Parent(Find(node, ByTag(atom.Html))))
+
+	b := Find(node, ByID("b"))
+	assert.Equal(t, 7, len(Children(b, nil)))
+	assert.Equal(t, 4, len(Children(b, ByTag(atom.A))))
+
+	siblings := Siblings(Find(node, ByID("bc")), nil)
+	assert.Equal(t, 6, len(siblings))
+	for _, s := range siblings {
+		assert.NotEqual(t, "bc", Attr(s, "id"))
+	}
+	assert.Equal(t, 2, len(Siblings(Find(node, ByID("a")), nil)))
+}
@@ -0,0 +1,326 @@
+package scrape
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Metadata is structured metadata embedded in a page.
+type Metadata struct {
+	// JSONLD is the parsed value of every JSON-LD block.
+	// A block with a top-level array is flattened.
+	JSONLD []interface{} `json:"jsonld,omitempty"`
+	// Microdata is the top-level schema.org Microdata items.
+	Microdata []*Item `json:"microdata,omitempty"`
+	// RDFa is the top-level RDFa Lite items.
+	RDFa []*Item `json:"rdfa,omitempty"`
+	// OpenGraph maps `og:*` properties without the prefix to their values.
+	OpenGraph map[string][]string `json:"opengraph,omitempty"`
+	// Twitter maps `twitter:*` card properties without the prefix to their values.
+	Twitter map[string][]string `json:"twitter,omitempty"`
+	// Warnings is problems found while extracting, such as invalid JSON-LD.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Item is a Microdata or RDFa item.
+type Item struct {
+	// Type is the absolute URLs of the types, such as `https://schema.org/Product`.
+	Type []string `json:"type,omitempty"`
+	ID   string   `json:"id,omitempty"`
+	// Properties maps property names to their values,
+	// which are string or `*Item`.
+	Properties map[string][]interface{} `json:"properties"`
+}
+
+// IsEmpty reports whether m has no metadata.
+func (m *Metadata) IsEmpty() bool {
+	return len(m.JSONLD) == 0 && len(m.Microdata) == 0 && len(m.RDFa) == 0 &&
+		len(m.OpenGraph) == 0 && len(m.Twitter) == 0 && len(m.Warnings) == 0
+}
+
+// ExtractMetadata extracts JSON-LD, Microdata, RDFa Lite, OpenGraph and
+// Twitter card metadata from the document.
+// Malformed metadata is skipped and reported in Warnings.
+func ExtractMetadata(root *html.Node) *Metadata {
+	m := &Metadata{}
+	m.extractJSONLD(root)
+	m.extractMicrodata(root)
+	m.extractRDFa(root)
+	m.extractMetaTags(root)
+	return m
+}
+
+func (m *Metadata) warnf(format string, args ...interface{}) {
+	m.Warnings = append(m.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (m *Metadata) extractJSONLD(root *html.Node) {
+	scripts := FindAll(root, func(n *html.Node) bool {
+		if n.DataAtom != atom.Script {
+			return false
+		}
+		mediaType, _, err := mime.ParseMediaType(Attr(n, "type"))
+		return err == nil && mediaType == "application/ld+json"
+	})
+	for i, s := range scripts {
+		var b strings.Builder
+		for c := s.FirstChild; c != nil; c = c.NextSibling {
+			b.WriteString(c.Data)
+		}
+		src := strings.TrimSpace(b.String())
+		// Some pages wrap the block in HTML comment or CDATA.
+		src = trimWrapper(src, "<!--", "-->")
+		src = trimWrapper(src, "<![CDATA[", "]]>")
+		if src == "" {
+			continue
+		}
+
+		var v interface{}
+		if err := json.Unmarshal([]byte(src), &v); err != nil {
+			m.warnf("JSON-LD block %d: %s", i+1, err)
+			continue
+		}
+		if list, ok := v.([]interface{}); ok {
+			m.JSONLD = append(m.JSONLD, list...)
+		} else {
+			m.JSONLD = append(m.JSONLD, v)
+		}
+	}
+}
+
+func trimWrapper(s, prefix, suffix string) string {
+	if strings.HasPrefix(s, prefix) && strings.HasSuffix(s, suffix) {
+		return strings.TrimSpace(s[len(prefix) : len(s)-len(suffix)])
+	}
+	return s
+}
+
+// extractMicrodata extracts items by the HTML Microdata algorithm.
+// Top-level items are elements with itemscope and without itemprop.
+func (m *Metadata) extractMicrodata(root *html.Node) {
+	ids := map[string]*html.Node{}
+	for _, n := range FindAll(root, func(n *html.Node) bool { return isElement(n) && Attr(n, "id") != "" }) {
+		if _, ok := ids[Attr(n, "id")]; !ok {
+			ids[Attr(n, "id")] = n
+		}
+	}
+
+	scopes := FindAll(root, func(n *html.Node) bool {
+		_, scope := attr(n, "itemscope")
+		_, prop := attr(n, "itemprop")
+		return isElement(n) && scope && !prop
+	})
+	for _, n := range scopes {
+		m.Microdata = append(m.Microdata, m.microdataItem(n, ids, map[*html.Node]bool{}))
+	}
+}
+
+// microdataItem returns the item of scope. visiting prevents infinite
+// recursion through itemref.
+func (m *Metadata) microdataItem(scope *html.Node, ids map[string]*html.Node, visiting map[*html.Node]bool) *Item {
+	visiting[scope] = true
+	defer delete(visiting, scope)
+
+	item := &Item{
+		ID:         Attr(scope, "itemid"),
+		Properties: map[string][]interface{}{},
+	}
+	if types := strings.Fields(Attr(scope, "itemtype")); len(types) > 0 {
+		item.Type = types
+	}
+
+	// Properties are in children of scope and elements referenced by itemref.
+	pending := make([]*html.Node, 0)
+	for c := scope.FirstChild; c != nil; c = c.NextSibling {
+		pending = append(pending, c)
+	}
+	for _, id := range strings.Fields(Attr(scope, "itemref")) {
+		ref, ok := ids[id]
+		if !ok {
+			m.warnf("Microdata: itemref to unknown id %q", id)
+			continue
+		}
+		pending = append(pending, ref)
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if !isElement(n) {
+			return
+		}
+		_, scoped := attr(n, "itemscope")
+		if names := strings.Fields(Attr(n, "itemprop")); len(names) > 0 {
+			var v interface{}
+			switch {
+			case scoped && visiting[n]:
+				m.warnf("Microdata: recursive item %q", names[0])
+				return
+			case scoped:
+				v = m.microdataItem(n, ids, visiting)
+			default:
+				v = microdataValue(n)
+			}
+			for _, name := range names {
+				item.Properties[name] = append(item.Properties[name], v)
+			}
+		}
+		if scoped {
+			// Descendants of a nested item belong to that item.
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range pending {
+		walk(n)
+	}
+	return item
+}
+
+// microdataValue returns the property value of element.
+func microdataValue(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.Meta:
+		return Attr(n, "content")
+	case atom.Audio, atom.Embed, atom.Iframe, atom.Img, atom.Source, atom.Track, atom.Video:
+		return Attr(n, "src")
+	case atom.A, atom.Area, atom.Link:
+		return Attr(n, "href")
+	case atom.Object:
+		return Attr(n, "data")
+	case atom.Data, atom.Meter:
+		return Attr(n, "value")
+	case atom.Time:
+		if v, ok := attr(n, "datetime"); ok {
+			return v
+		}
+	}
+	return Text(n)
+}
+
+// extractRDFa extracts RDFa Lite items. Top-level items are elements with
+// typeof which are not a property of another item.
+func (m *Metadata) extractRDFa(root *html.Node) {
+	var walk func(n *html.Node, vocab string, prefixes map[string]string, parent *Item)
+	walk = func(n *html.Node, vocab string, prefixes map[string]string, parent *Item) {
+		if !isElement(n) {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c, vocab, prefixes, parent)
+			}
+			return
+		}
+		if v, ok := attr(n, "vocab"); ok {
+			vocab = v
+		}
+		if _, ok := attr(n, "prefix"); ok {
+			prefixes = rdfaPrefixes(n, prefixes)
+		}
+
+		names, hasProp := attr(n, "property")
+		types, hasType := attr(n, "typeof")
+		var item *Item
+		if hasType {
+			item = &Item{ID: Attr(n, "resource"), Properties: map[string][]interface{}{}}
+			for _, t := range strings.Fields(types) {
+				item.Type = append(item.Type, expandTerm(t, vocab, prefixes))
+			}
+		}
+
+		if hasProp && parent != nil {
+			var v interface{}
+			if item != nil {
+				v = item
+			} else {
+				v = rdfaValue(n)
+			}
+			for _, name := range strings.Fields(names) {
+				parent.Properties[name] = append(parent.Properties[name], v)
+			}
+		} else if item != nil {
+			m.RDFa = append(m.RDFa, item)
+		}
+
+		if item != nil {
+			parent = item
+		}
+		if hasProp && item == nil && parent != nil {
+			// Text of a literal property is not searched for more properties.
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, vocab, prefixes, parent)
+		}
+	}
+	walk(root, "", nil, nil)
+}
+
+// rdfaPrefixes returns inherited prefix mappings with ones declared by
+// prefix attribute, such as `og: http://ogp.me/ns#`.
+func rdfaPrefixes(n *html.Node, inherited map[string]string) map[string]string {
+	fields := strings.Fields(Attr(n, "prefix"))
+	prefixes := map[string]string{}
+	for k, v := range inherited {
+		prefixes[k] = v
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		prefixes[strings.TrimSuffix(fields[i], ":")] = fields[i+1]
+	}
+	return prefixes
+}
+
+// expandTerm expands term by vocab or prefix to an absolute URL.
+func expandTerm(term, vocab string, prefixes map[string]string) string {
+	if i := strings.IndexByte(term, ':'); i >= 0 {
+		if ns, ok := prefixes[term[:i]]; ok {
+			return ns + term[i+1:]
+		}
+		return term
+	}
+	return vocab + term
+}
+
+// rdfaValue returns the property value of element.
+func rdfaValue(n *html.Node) string {
+	for _, key := range []string{"content", "resource", "href", "src"} {
+		if v, ok := attr(n, key); ok {
+			return v
+		}
+	}
+	if n.DataAtom == atom.Time {
+		if v, ok := attr(n, "datetime"); ok {
+			return v
+		}
+	}
+	return Text(n)
+}
+
+// extractMetaTags extracts OpenGraph and Twitter card properties from meta elements.
+// Both property and name attributes are accepted because pages use either.
+func (m *Metadata) extractMetaTags(root *html.Node) {
+	for _, n := range FindAll(root, ByTag(atom.Meta)) {
+		key := Attr(n, "property")
+		if key == "" {
+			key = Attr(n, "name")
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		content := strings.TrimSpace(Attr(n, "content"))
+		switch {
+		case strings.HasPrefix(key, "og:"):
+			if m.OpenGraph == nil {
+				m.OpenGraph = map[string][]string{}
+			}
+			m.OpenGraph[key[3:]] = append(m.OpenGraph[key[3:]], content)
+		case strings.HasPrefix(key, "twitter:"):
+			if m.Twitter == nil {
+				m.Twitter = map[string][]string{}
+			}
+			m.Twitter[key[8:]] = append(m.Twitter[key[8:]], content)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package scrape
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func parseMetadata(t *testing.T, s string) *Metadata {
+	root, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ExtractMetadata(root)
+}
+
+func TestExtractMetadataJSONLD(t *testing.T) {
+	m := parseMetadata(t, `<html><head>
+<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Product", "name": "Widget"}</script>
+<script type="application/ld+json; charset=utf-8">
+<!-- [{"@type": "Organization"}, {"@type": "WebSite"}] -->
+</script>
+<script type="application/ld+json">{"@type": "Broken",}</script>
+<script type="application/ld+json">  </script>
+<script>{"@type": "NotJSONLD"}</script>
+</head></html>`)
+
+	expected := []interface{}{
+		map[string]interface{}{"@context": "https://schema.org", "@type": "Product", "name": "Widget"},
+		map[string]interface{}{"@type": "Organization"},
+		map[string]interface{}{"@type": "WebSite"},
+	}
+	assert.Equal(t, expected, m.JSONLD)
+	if assert.Len(t, m.Warnings, 1) {
+		assert.Contains(t, m.Warnings[0], "JSON-LD block 3")
+	}
+}
+
+func TestExtractMetadataMicrodata(t *testing.T) {
+	m := parseMetadata(t, `<html><body>
+<div itemscope itemtype="https://schema.org/Product" itemid="urn:sku:42" itemref="extra missing">
+  <h1 itemprop="name">Widget</h1>
+  <img itemprop="image" src="/w.png">
+  <a itemprop="url" href="/p/42">link</a>
+  <meta itemprop="sku" content="42">
+  <time itemprop="releaseDate" datetime="2020-05-01">May 1</time>
+  <div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+    <span itemprop="price">12.50</span>
+    <span itemprop="priceCurrency">USD</span>
+  </div>
+  <span itemprop="category keywords">tools</span>
+</div>
+<p id="extra" itemprop="description">A widget.</p>
+<div itemscope><span itemprop="name">Other</span></div>
+</body></html>`)
+
+	expected := []*Item{
+		{
+			Type: []string{"https://schema.org/Product"},
+			ID:   "urn:sku:42",
+			Properties: map[string][]interface{}{
+				"name":        {"Widget"},
+				"image":       {"/w.png"},
+				"url":         {"/p/42"},
+				"sku":         {"42"},
+				"releaseDate": {"2020-05-01"},
+				"offers": {&Item{
+					Type: []string{"https://schema.org/Offer"},
+					Properties: map[string][]interface{}{
+						"price":         {"12.50"},
+						"priceCurrency": {"USD"},
+					},
+				}},
+				"category":    {"tools"},
+				"keywords":    {"tools"},
+				"description": {"A widget."},
+			},
+		},
+		{Properties: map[string][]interface{}{"name": {"Other"}}},
+	}
+	assert.Equal(t, expected, m.Microdata)
+	assert.Equal(t, []string{`Microdata: itemref to unknown id "missing"`}, m.Warnings)
+}
+
+func TestExtractMetadataMicrodataRecursive(t *testing.T) {
+	m := parseMetadata(t, `<div itemscope itemref="a"><div id="a" itemprop="self" itemscope itemref="a"></div></div>`)
+	assert.Len(t, m.Microdata, 1)
+	assert.NotEmpty(t, m.Warnings)
+}
+
+func TestExtractMetadataRDFa(t *testing.T) {
+	m := parseMetadata(t, `<html><body vocab="https://schema.org/" prefix="ex: http://example.com/ns#">
+<div typeof="Person" resource="#alice">
+  <span property="name">Alice</span>
+  <a property="url" href="https://alice.example.com">home</a>
+  <div property="address" typeof="PostalAddress">
+    <span property="addressLocality">Tokyo</span>
+  </div>
+  <meta property="ex:nick" content="al">
+</div>
+<div typeof="ex:Thing"><span property="name">Thing</span></div>
+</body></html>`)
+
+	expected := []*Item{
+		{
+			Type: []string{"https://schema.org/Person"},
+			ID:   "#alice",
+			Properties: map[string][]interface{}{
+				"name": {"Alice"},
+				"url":  {"https://alice.example.com"},
+				"address": {&Item{
+					Type:       []string{"https://schema.org/PostalAddress"},
+					Properties: map[string][]interface{}{"addressLocality": {"Tokyo"}},
+				}},
+				"ex:nick": {"al"},
+			},
+		},
+		{
+			Type:       []string{"http://example.com/ns#Thing"},
+			Properties: map[string][]interface{}{"name": {"Thing"}},
+		},
+	}
+	assert.Equal(t, expected, m.RDFa)
+}
+
+func TestExtractMetadataMetaTags(t *testing.T) {
+	m := parseMetadata(t, `<html><head>
+<meta property="og:title" content="Title">
+<meta property="og:image" content="/a.png">
+<meta property="og:image" content="/b.png">
+<meta name="twitter:card" content="summary">
+<meta property="twitter:site" content="@example">
+<meta name="description" content="ignored">
+</head></html>`)
+
+	assert.Equal(t, map[string][]string{"title": {"Title"}, "image": {"/a.png", "/b.png"}}, m.OpenGraph)
+	assert.Equal(t, map[string][]string{"card": {"summary"}, "site": {"@example"}}, m.Twitter)
+	assert.Empty(t, m.RDFa)
+	assert.False(t, m.IsEmpty())
+	assert.True(t, parseMetadata(t, `<p>no metadata</p>`).IsEmpty())
+}
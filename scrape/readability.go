@@ -0,0 +1,365 @@
+package scrape
+
+import (
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Article is the main content of a page found by ExtractArticle.
+type Article struct {
+	Title  string
+	Byline string
+	// Date is the publish date. It is zero if the page does not have it.
+	Date time.Time
+	// LeadImage is the URL of the main image as written in the page.
+	LeadImage string
+	// Content is the element that contains the main content.
+	Content *html.Node
+	// Text is the text of Content without boilerplate, with paragraphs
+	// separated by a blank line.
+	Text string
+}
+
+var (
+	unlikelyCandidates = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|consent|disqus|extra|foot|header|legends|menu|modal|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|ad-break|agegate|pagination|pager|popup|promo|newsletter|subscribe`)
+	maybeCandidates    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveWeight     = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeWeight     = regexp.MustCompile(`(?i)hidden|^hid$|hid$|hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget|cookie|consent`)
+	bylineClass        = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+)
+
+// articleDateLayouts are layouts of publish date in meta elements and
+// datetime attributes.
+var articleDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// ExtractArticle finds the main content of the document by scoring
+// paragraphs and their ancestors, and removes boilerplate such as
+// navigation, footers and banners. The document is not modified.
+func ExtractArticle(root *html.Node) *Article {
+	a := &Article{
+		Title:     articleTitle(root),
+		Byline:    articleByline(root),
+		Date:      articleDate(root),
+		LeadImage: metaContent(root, "og:image", "twitter:image"),
+	}
+	a.Content = topCandidate(root)
+	if a.Content == nil {
+		a.Content = Find(root, ByTag(atom.Body))
+		if a.Content == nil {
+			a.Content = root
+		}
+	}
+	if a.LeadImage == "" {
+		if img := Find(a.Content, func(n *html.Node) bool {
+			return n.DataAtom == atom.Img && Attr(n, "src") != "" && !isBoilerplate(n)
+		}); img != nil {
+			a.LeadImage = Attr(img, "src")
+		}
+	}
+	a.Text = articleText(a.Content)
+	return a
+}
+
+// Markdown returns the article as Markdown with the title as heading.
+// Relative URLs are resolved against base if it is not nil.
+func (a *Article) Markdown(base *url.URL) string {
+	mc := &markdownConverter{
+		base:      base,
+		skip:      func(n *html.Node) bool { return isNonContent(n) || isBoilerplate(n) },
+		skipTitle: a.Title,
+	}
+	var b strings.Builder
+	if a.Title != "" {
+		b.WriteString("# " + escapeMarkdown(a.Title) + "\n\n")
+	}
+	var info []string
+	if a.Byline != "" {
+		info = append(info, escapeMarkdown(a.Byline))
+	}
+	if !a.Date.IsZero() {
+		info = append(info, a.Date.Format("2006-01-02"))
+	}
+	if len(info) > 0 {
+		b.WriteString("_" + strings.Join(info, " · ") + "_\n\n")
+	}
+	// The lead image found in Content is written in place.
+	if a.LeadImage != "" && Find(a.Content, ByAttr("src", a.LeadImage)) == nil {
+		b.WriteString("![](" + mc.url(a.LeadImage) + ")\n\n")
+	}
+	b.WriteString(mc.convert(a.Content))
+	return b.String()
+}
+
+func articleTitle(root *html.Node) string {
+	if t := metaContent(root, "og:title", "twitter:title"); t != "" {
+		return t
+	}
+	h1s := FindAll(root, ByTag(atom.H1))
+	if len(h1s) == 1 {
+		if t := Text(h1s[0]); t != "" {
+			return t
+		}
+	}
+	if t := Find(root, ByTag(atom.Title)); t != nil {
+		return Text(t)
+	}
+	return ""
+}
+
+func articleByline(root *html.Node) string {
+	if s := metaContent(root, "author", "article:author"); s != "" && !strings.HasPrefix(s, "http") {
+		return s
+	}
+	n := Find(root, func(n *html.Node) bool {
+		if !isElement(n) || n.DataAtom == atom.Meta || n.DataAtom == atom.Link {
+			return false
+		}
+		if Attr(n, "rel") == "author" || Attr(n, "itemprop") == "author" {
+			return true
+		}
+		return bylineClass.MatchString(Attr(n, "class") + " " + Attr(n, "id"))
+	})
+	if n == nil {
+		return ""
+	}
+	s := Text(n)
+	if len(s) > 100 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(s, "By "), "by "))
+}
+
+func articleDate(root *html.Node) time.Time {
+	candidates := []string{metaContent(root, "article:published_time", "datePublished", "date", "pubdate", "dc.date")}
+	if n := Find(root, func(n *html.Node) bool {
+		return n.DataAtom == atom.Time && Attr(n, "datetime") != ""
+	}); n != nil {
+		candidates = append(candidates, Attr(n, "datetime"))
+	}
+	for _, s := range candidates {
+		for _, layout := range articleDateLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// metaContent returns content of the first meta element whose property,
+// name or itemprop is one of keys, in the order of keys.
+func metaContent(root *html.Node, keys ...string) string {
+	metas := FindAll(root, ByTag(atom.Meta))
+	for _, key := range keys {
+		for _, m := range metas {
+			for _, a := range []string{"property", "name", "itemprop"} {
+				if strings.EqualFold(Attr(m, a), key) {
+					if c := strings.TrimSpace(Attr(m, "content")); c != "" {
+						return c
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// isBoilerplate reports whether the element is not a part of the main content.
+func isBoilerplate(n *html.Node) bool {
+	if !isElement(n) {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Nav, atom.Aside,
+		atom.Footer, atom.Form, atom.Button, atom.Iframe, atom.Select, atom.Input, atom.Svg:
+		return true
+	case atom.Header:
+		// Header of the article often has the title and byline.
+		return Closest(n, ByTag(atom.Article)) == nil
+	case atom.Body, atom.Html, atom.Article, atom.Main:
+		return false
+	}
+	if isHidden(n) {
+		return true
+	}
+	if role := Attr(n, "role"); role == "navigation" || role == "banner" || role == "complementary" || role == "contentinfo" || role == "dialog" {
+		return true
+	}
+	s := Attr(n, "class") + " " + Attr(n, "id")
+	return unlikelyCandidates.MatchString(s) && !maybeCandidates.MatchString(s)
+}
+
+// isHidden reports whether the element is hidden by attributes.
+func isHidden(n *html.Node) bool {
+	if _, ok := attr(n, "hidden"); ok {
+		return true
+	}
+	if Attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	style := strings.ToLower(strings.Replace(Attr(n, "style"), " ", "", -1))
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// topCandidate returns the element with the highest content score.
+func topCandidate(root *html.Node) *html.Node {
+	scores := map[*html.Node]float64{}
+	order := make([]*html.Node, 0)
+	initialize := func(n *html.Node) {
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			order = append(order, n)
+		}
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if isBoilerplate(n) {
+			return
+		}
+		if isParagraph(n) {
+			text := contentText(n)
+			if len(text) >= 25 {
+				score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+				if p := Parent(n); p != nil {
+					initialize(p)
+					scores[p] += score
+					if gp := Parent(p); gp != nil {
+						initialize(gp)
+						scores[gp] += score / 2
+					}
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	var top *html.Node
+	var topScore float64
+	for _, n := range order {
+		score := scores[n] * (1 - linkDensity(n))
+		if top == nil || score > topScore {
+			top, topScore = n, score
+		}
+	}
+	return top
+}
+
+// isParagraph reports whether the element is a unit of text to score.
+func isParagraph(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.P, atom.Pre, atom.Td, atom.Blockquote, atom.Li:
+		return true
+	case atom.Div, atom.Section:
+		// div without block children is used as paragraph.
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if isElement(c) && isBlock(c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		score = 10
+	case atom.Div:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	for _, s := range []string{Attr(n, "class"), Attr(n, "id")} {
+		if s == "" {
+			continue
+		}
+		if negativeWeight.MatchString(s) {
+			score -= 25
+		}
+		if positiveWeight.MatchString(s) {
+			score += 25
+		}
+	}
+	return score
+}
+
+// linkDensity returns the ratio of the length of link text to all text.
+func linkDensity(n *html.Node) float64 {
+	text := len(contentText(n))
+	if text == 0 {
+		return 0
+	}
+	links := 0
+	for _, a := range FindAll(n, ByTag(atom.A)) {
+		links += len(Text(a))
+	}
+	return float64(links) / float64(text)
+}
+
+// contentText returns text of n without boilerplate, in a single line.
+func contentText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if isBoilerplate(n) {
+			return
+		}
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// articleText returns text of n without boilerplate, rendered by
+// DefaultTextOptions.
+func articleText(n *html.Node) string {
+	tr := &textRenderer{opts: DefaultTextOptions, skip: isBoilerplate}
+	tr.render(n)
+	return tr.String()
+}
+
+// isBlock reports whether the element is rendered as a block.
+func isBlock(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Address, atom.Article, atom.Aside, atom.Blockquote, atom.Body, atom.Dd, atom.Details,
+		atom.Dialog, atom.Div, atom.Dl, atom.Dt, atom.Fieldset, atom.Figcaption, atom.Figure,
+		atom.Footer, atom.Form, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+		atom.Header, atom.Hr, atom.Html, atom.Li, atom.Main, atom.Nav, atom.Ol, atom.P,
+		atom.Pre, atom.Section, atom.Summary, atom.Table, atom.Tbody, atom.Td, atom.Tfoot,
+		atom.Th, atom.Thead, atom.Tr, atom.Ul, atom.Caption:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package scrape
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+const testArticlePage = `<html><head>
+<title>How to brew coffee | Example Blog</title>
+<meta property="article:published_time" content="2020-05-01T09:00:00Z">
+<script>var tracking = 1;</script>
+</head><body>
+<header class="site-header"><a href="/">Example Blog</a></header>
+<nav><a href="/">Home</a> <a href="/about">About</a> <a href="/contact">Contact</a></nav>
+<div id="cookie-banner">We use cookies, lots of cookies, to improve your experience.</div>
+<div class="layout">
+  <article class="post">
+    <h1>How to brew coffee</h1>
+    <p class="byline">By Jane Doe</p>
+    <img src="/images/coffee.jpg" alt="A cup">
+    <p>Brewing coffee at home is easy, cheap, and rewarding, once you know the basics of extraction.</p>
+    <p>Start with fresh beans, grind them just before brewing, and use water slightly below boiling.</p>
+    <div class="share">Share on <a href="https://twitter.com">Twitter</a>, <a href="https://facebook.com">Facebook</a></div>
+    <p>Finally, experiment with the ratio of coffee to water, and keep notes of what you like.</p>
+  </article>
+  <aside class="sidebar"><p>Related posts, popular posts, and other things you might like to read.</p></aside>
+</div>
+<footer><p>Copyright 2020, Example Blog, all rights reserved, do not copy this.</p></footer>
+</body></html>`
+
+func TestExtractArticle(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(testArticlePage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := ExtractArticle(root)
+
+	assert.Equal(t, "How to brew coffee", a.Title)
+	assert.Equal(t, "Jane Doe", a.Byline)
+	assert.Equal(t, time.Date(2020, 5, 1, 9, 0, 0, 0, time.UTC), a.Date)
+	assert.Equal(t, "/images/coffee.jpg", a.LeadImage)
+	assert.Equal(t, "article", a.Content.Data)
+
+	expected := "How to brew coffee\n\n" +
+		"By Jane Doe\n\n" +
+		"Brewing coffee at home is easy, cheap, and rewarding, once you know the basics of extraction.\n\n" +
+		"Start with fresh beans, grind them just before brewing, and use water slightly below boiling.\n\n" +
+		"Finally, experiment with the ratio of coffee to water, and keep notes of what you like."
+	assert.Equal(t, expected, a.Text)
+}
+
+func TestExtractArticleMetaTags(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(`<html><head>
+<meta property="og:title" content="OG Title">
+<meta name="author" content="John Roe">
+<meta property="og:image" content="https://example.com/lead.png">
+</head><body><h1>First</h1><h1>Second</h1><time datetime="2020-05-02">May 2</time>
+<div><p>Just a short page with a single paragraph, which is long enough to be scored.</p></div>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := ExtractArticle(root)
+	assert.Equal(t, "OG Title", a.Title)
+	assert.Equal(t, "John Roe", a.Byline)
+	assert.Equal(t, "https://example.com/lead.png", a.LeadImage)
+	assert.Equal(t, time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC), a.Date)
+	assert.Equal(t, "Just a short page with a single paragraph, which is long enough to be scored.", a.Text)
+}
+
+func TestExtractArticleText(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(`<html><body><article>
+<p>Run the command below, which prints the version of the tool installed.</p>
+<pre>$ grawl -v
+  v1.0</pre>
+<p aria-hidden="true">Hidden text</p><input type="hidden" value="x">
+<ul><li>first</li><li>second</li></ul>
+</article></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := ExtractArticle(root)
+	expected := "Run the command below, which prints the version of the tool installed.\n\n" +
+		"$ grawl -v\n  v1.0\n\n" +
+		"first\nsecond"
+	assert.Equal(t, expected, a.Text)
+}
+
+func TestExtractArticleEmpty(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(`<p>short</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := ExtractArticle(root)
+	assert.Equal(t, "body", a.Content.Data)
+	assert.Equal(t, "short", a.Text)
+	assert.True(t, a.Date.IsZero())
+}
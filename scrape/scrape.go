@@ -24,6 +24,62 @@ func FindAll(node *html.Node, matcher func(*html.Node) bool) []*html.Node {
 	return nodes
 }
 
+// Find return the first node in document order that match matcher function.
+// It stops walking the tree at the first match, and returns nil if no node match.
+func Find(node *html.Node, matcher func(*html.Node) bool) *html.Node {
+	if matcher(node) {
+		return node
+	}
+	for n := node.FirstChild; n != nil; n = n.NextSibling {
+		if found := Find(n, matcher); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Closest return node itself or its nearest ancestor that match matcher function.
+// It returns nil if no node match.
+func Closest(node *html.Node, matcher func(*html.Node) bool) *html.Node {
+	for n := node; n != nil; n = n.Parent {
+		if matcher(n) {
+			return n
+		}
+	}
+	return nil
+}
+
+// Parent return parent element of node. It returns nil for the root element.
+func Parent(node *html.Node) *html.Node {
+	if node.Parent == nil || node.Parent.Type != html.ElementNode {
+		return nil
+	}
+	return node.Parent
+}
+
+// Children return child elements of node that match matcher function.
+// When matcher is nil, all child elements are returned.
+func Children(node *html.Node, matcher func(*html.Node) bool) []*html.Node {
+	nodes := make([]*html.Node, 0)
+	for n := node.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode && (matcher == nil || matcher(n)) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// Siblings return sibling elements of node that match matcher function,
+// excluding node itself. When matcher is nil, all sibling elements are returned.
+func Siblings(node *html.Node, matcher func(*html.Node) bool) []*html.Node {
+	if node.Parent == nil {
+		return []*html.Node{}
+	}
+	return Children(node.Parent, func(n *html.Node) bool {
+		return n != node && (matcher == nil || matcher(n))
+	})
+}
+
 // Attr return value of attribute.
 func Attr(node *html.Node, key string) string {
 	for _, v := range node.Attr {
@@ -34,6 +90,17 @@ func Attr(node *html.Node, key string) string {
 	return ""
 }
 
+// HasToken reports whether the space separated list s, such as the value of
+// rel attribute, includes token case insensitively.
+func HasToken(s, token string) bool {
+	for _, t := range strings.Fields(s) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
 // Text return joined textdata from all child `html.TextNode` node.
 func Text(node *html.Node) string {
 	textNodes := FindAll(node, func(n *html.Node) bool {
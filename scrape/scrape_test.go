@@ -72,6 +72,13 @@ func TestAttr(t *testing.T) {
 	}
 }
 
+func TestHasToken(t *testing.T) {
+	assert.True(t, HasToken("alternate", "alternate"))
+	assert.True(t, HasToken(" Shortcut  ICON ", "icon"))
+	assert.False(t, HasToken("alternate-feed", "alternate"))
+	assert.False(t, HasToken("", "alternate"))
+}
+
 func TestText(t *testing.T) {
 	node, err := html.Parse(strings.NewReader(testHTML))
 	assert.NoError(t, err)
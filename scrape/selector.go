@@ -0,0 +1,610 @@
+package scrape
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrInvalidSelector is the error thrown if CSS selector can not be parsed.
+var ErrInvalidSelector = errors.New("Invalid selector")
+
+// Select returns all nodes that match CSS selector.
+func Select(node *html.Node, selector string) ([]*html.Node, error) {
+	m, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return FindAll(node, m), nil
+}
+
+// SelectFirst returns the first node that matches CSS selector.
+// It returns nil if no node matches.
+func SelectFirst(node *html.Node, selector string) (*html.Node, error) {
+	m, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return Find(node, m), nil
+}
+
+// CompileSelector returns `Matcher` that match node selected by CSS selector.
+// It supports CSS3 selectors:
+//   - type, universal, `#id`, `.class`
+//   - attribute `[a]`, `[a=v]`, `[a~=v]`, `[a|=v]`, `[a^=v]`, `[a$=v]`, `[a*=v]`
+//   - combinators of descendant, child `>`, adjacent sibling `+` and general sibling `~`
+//   - `:nth-child()`, `:nth-last-child()`, `:nth-of-type()`, `:nth-last-of-type()`,
+//     `:first-child`, `:last-child`, `:only-child`, `:first-of-type`, `:last-of-type`,
+//     `:only-of-type`, `:empty`, `:root`, `:checked`, `:disabled`, `:enabled` and `:not()`
+//   - selector group separated by comma
+func CompileSelector(selector string) (Matcher, error) {
+	p := &selectorParser{s: selector}
+	m, err := p.parseGroup()
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.i])
+	}
+	return m, nil
+}
+
+// MustCompileSelector is like CompileSelector but panics if the selector
+// can not be parsed.
+func MustCompileSelector(selector string) Matcher {
+	m, err := CompileSelector(selector)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+type selectorParser struct {
+	s string
+	i int
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%v: %s at %d in %q", ErrInvalidSelector, fmt.Sprintf(format, args...), p.i, p.s)
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.i
+	for p.i < len(p.s) && isSpace(p.s[p.i]) {
+		p.i++
+	}
+	return p.i > start
+}
+
+func (p *selectorParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+// parseGroup parses selectors separated by comma.
+func (p *selectorParser) parseGroup() (Matcher, error) {
+	matchers := make([]Matcher, 0, 1)
+	for {
+		p.skipSpace()
+		m, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+		p.skipSpace()
+		if p.peek() != ',' {
+			break
+		}
+		p.i++
+	}
+	if len(matchers) == 1 {
+		return matchers[0], nil
+	}
+	return func(n *html.Node) bool {
+		for _, m := range matchers {
+			if m(n) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// parseComplex parses compound selectors joined by combinators.
+func (p *selectorParser) parseComplex() (Matcher, error) {
+	first, err := p.parseCompound()
+	if err != nil {
+		return nil, err
+	}
+	parts := []Matcher{first}
+	combinators := []byte{}
+	for {
+		space := p.skipSpace()
+		c := p.peek()
+		switch {
+		case c == '>' || c == '+' || c == '~':
+			p.i++
+			p.skipSpace()
+		case space && c != 0 && c != ',' && c != ')':
+			c = ' '
+		default:
+			return complexMatcher(parts, combinators), nil
+		}
+		m, err := p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, m)
+		combinators = append(combinators, c)
+	}
+}
+
+func complexMatcher(parts []Matcher, combinators []byte) Matcher {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	var match func(i int, n *html.Node) bool
+	match = func(i int, n *html.Node) bool {
+		if !parts[i](n) {
+			return false
+		}
+		if i == 0 {
+			return true
+		}
+		switch combinators[i-1] {
+		case ' ':
+			for a := n.Parent; a != nil; a = a.Parent {
+				if match(i-1, a) {
+					return true
+				}
+			}
+		case '>':
+			return n.Parent != nil && match(i-1, n.Parent)
+		case '+':
+			s := prevElementSibling(n)
+			return s != nil && match(i-1, s)
+		case '~':
+			for s := prevElementSibling(n); s != nil; s = prevElementSibling(s) {
+				if match(i-1, s) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return func(n *html.Node) bool {
+		return match(len(parts)-1, n)
+	}
+}
+
+// parseCompound parses type selector followed by id, class, attribute
+// and pseudo-class selectors.
+func (p *selectorParser) parseCompound() (Matcher, error) {
+	matchers := []Matcher{isElement}
+	start := p.i
+	if p.peek() == '*' {
+		p.i++
+	} else if isIdentStart(p.s, p.i) {
+		tag := strings.ToLower(p.parseIdent())
+		matchers = append(matchers, func(n *html.Node) bool {
+			return n.Data == tag
+		})
+	}
+
+loop:
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case '#':
+			p.i++
+			if !isNameChar(p.peek()) && p.peek() != '\\' {
+				return nil, p.errorf("expected id")
+			}
+			id := p.parseIdent()
+			matchers = append(matchers, func(n *html.Node) bool {
+				return Attr(n, "id") == id
+			})
+		case '.':
+			p.i++
+			if !isIdentStart(p.s, p.i) {
+				return nil, p.errorf("expected class name")
+			}
+			class := p.parseIdent()
+			matchers = append(matchers, func(n *html.Node) bool {
+				return includesWord(Attr(n, "class"), class)
+			})
+		case '[':
+			m, err := p.parseAttr()
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, m)
+		case ':':
+			m, err := p.parsePseudo()
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, m)
+		default:
+			break loop
+		}
+	}
+	if p.i == start {
+		if p.i < len(p.s) {
+			return nil, p.errorf("unexpected %q", p.s[p.i])
+		}
+		return nil, p.errorf("expected selector")
+	}
+
+	return func(n *html.Node) bool {
+		for _, m := range matchers {
+			if !m(n) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// parseAttr parses attribute selector.
+func (p *selectorParser) parseAttr() (Matcher, error) {
+	p.i++ // [
+	p.skipSpace()
+	if !isIdentStart(p.s, p.i) {
+		return nil, p.errorf("expected attribute name")
+	}
+	key := strings.ToLower(p.parseIdent())
+	p.skipSpace()
+
+	if p.peek() == ']' {
+		p.i++
+		return func(n *html.Node) bool {
+			_, ok := attr(n, key)
+			return ok
+		}, nil
+	}
+
+	op := ""
+	if p.peek() == '=' {
+		op = "="
+		p.i++
+	} else if p.i+1 < len(p.s) && p.s[p.i+1] == '=' && strings.IndexByte("~|^$*", p.s[p.i]) >= 0 {
+		op = p.s[p.i : p.i+2]
+		p.i += 2
+	} else {
+		return nil, p.errorf("expected attribute operator")
+	}
+	p.skipSpace()
+
+	var val string
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		val = s
+	case isIdentStart(p.s, p.i):
+		val = p.parseIdent()
+	default:
+		return nil, p.errorf("expected attribute value")
+	}
+	p.skipSpace()
+	if p.peek() != ']' {
+		return nil, p.errorf("expected ]")
+	}
+	p.i++
+
+	var match func(string) bool
+	switch op {
+	case "=":
+		match = func(v string) bool { return v == val }
+	case "~=":
+		match = func(v string) bool { return includesWord(v, val) }
+	case "|=":
+		match = func(v string) bool { return v == val || strings.HasPrefix(v, val+"-") }
+	case "^=":
+		match = func(v string) bool { return val != "" && strings.HasPrefix(v, val) }
+	case "$=":
+		match = func(v string) bool { return val != "" && strings.HasSuffix(v, val) }
+	case "*=":
+		match = func(v string) bool { return val != "" && strings.Contains(v, val) }
+	}
+	return func(n *html.Node) bool {
+		v, ok := attr(n, key)
+		return ok && match(v)
+	}, nil
+}
+
+// parsePseudo parses pseudo-class selector.
+func (p *selectorParser) parsePseudo() (Matcher, error) {
+	p.i++ // :
+	if !isIdentStart(p.s, p.i) {
+		return nil, p.errorf("expected pseudo-class")
+	}
+	name := strings.ToLower(p.parseIdent())
+
+	switch name {
+	case "first-child":
+		return nthMatcher(0, 1, false, false), nil
+	case "last-child":
+		return nthMatcher(0, 1, true, false), nil
+	case "only-child":
+		return And(nthMatcher(0, 1, false, false), nthMatcher(0, 1, true, false)), nil
+	case "first-of-type":
+		return nthMatcher(0, 1, false, true), nil
+	case "last-of-type":
+		return nthMatcher(0, 1, true, true), nil
+	case "only-of-type":
+		return And(nthMatcher(0, 1, false, true), nthMatcher(0, 1, true, true)), nil
+	case "empty":
+		return func(n *html.Node) bool {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode || c.Type == html.TextNode && c.Data != "" {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "root":
+		return func(n *html.Node) bool {
+			return n.Parent != nil && n.Parent.Type == html.DocumentNode
+		}, nil
+	case "checked":
+		return func(n *html.Node) bool {
+			_, checked := attr(n, "checked")
+			_, selected := attr(n, "selected")
+			return n.Data == "input" && checked || n.Data == "option" && selected
+		}, nil
+	case "disabled":
+		return isDisabled, nil
+	case "enabled":
+		return func(n *html.Node) bool {
+			switch n.Data {
+			case "button", "input", "select", "textarea", "option", "optgroup", "fieldset":
+				return !isDisabled(n)
+			}
+			return false
+		}, nil
+	}
+
+	if p.peek() != '(' {
+		return nil, p.errorf("unknown pseudo-class %q", name)
+	}
+	p.i++
+	p.skipSpace()
+
+	var m Matcher
+	switch name {
+	case "not":
+		inner, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		m = func(n *html.Node) bool { return !inner(n) }
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		end := strings.IndexByte(p.s[p.i:], ')')
+		if end < 0 {
+			return nil, p.errorf("expected )")
+		}
+		a, b, err := parseNth(p.s[p.i : p.i+end])
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.i += end
+		m = nthMatcher(a, b, strings.Contains(name, "last"), strings.HasSuffix(name, "of-type"))
+	default:
+		return nil, p.errorf("unknown pseudo-class %q", name)
+	}
+
+	p.skipSpace()
+	if p.peek() != ')' {
+		return nil, p.errorf("expected )")
+	}
+	p.i++
+	return m, nil
+}
+
+// parseNth parses `an+b`, `odd` and `even`.
+func parseNth(s string) (a, b int, err error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	case "":
+		return 0, 0, errors.New("empty nth expression")
+	}
+
+	i := strings.IndexByte(s, 'n')
+	if i < 0 {
+		b, err = strconv.Atoi(s)
+		return 0, b, err
+	}
+	switch s[:i] {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		if a, err = strconv.Atoi(s[:i]); err != nil {
+			return 0, 0, err
+		}
+	}
+	if rest := s[i+1:]; rest != "" {
+		if rest[0] != '+' && rest[0] != '-' {
+			return 0, 0, fmt.Errorf("invalid nth expression %q", s)
+		}
+		if b, err = strconv.Atoi(rest); err != nil {
+			return 0, 0, err
+		}
+	}
+	return a, b, nil
+}
+
+// nthMatcher returns `Matcher` that match element at position a*n+b
+// among its element siblings for some n >= 0.
+func nthMatcher(a, b int, last, ofType bool) Matcher {
+	return func(n *html.Node) bool {
+		if n.Type != html.ElementNode || n.Parent == nil {
+			return false
+		}
+		pos := 1
+		next := prevElementSibling
+		if last {
+			next = nextElementSibling
+		}
+		for s := next(n); s != nil; s = next(s) {
+			if !ofType || s.Data == n.Data {
+				pos++
+			}
+		}
+		if a == 0 {
+			return pos == b
+		}
+		return (pos-b)%a == 0 && (pos-b)/a >= 0
+	}
+}
+
+// parseIdent parses CSS identifier with escapes.
+func (p *selectorParser) parseIdent() string {
+	var b strings.Builder
+	if p.peek() == '-' {
+		b.WriteByte('-')
+		p.i++
+	}
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if c == '\\' {
+			b.WriteString(p.parseEscape())
+			continue
+		}
+		if !isNameChar(c) {
+			break
+		}
+		b.WriteByte(c)
+		p.i++
+	}
+	return b.String()
+}
+
+// parseEscape parses backslash escape.
+func (p *selectorParser) parseEscape() string {
+	p.i++ // backslash
+	start := p.i
+	for p.i < len(p.s) && p.i-start < 6 && isHex(p.s[p.i]) {
+		p.i++
+	}
+	if p.i > start {
+		r, _ := strconv.ParseUint(p.s[start:p.i], 16, 32)
+		if p.i < len(p.s) && isSpace(p.s[p.i]) {
+			p.i++
+		}
+		return string(rune(r))
+	}
+	if p.i < len(p.s) {
+		p.i++
+		return p.s[p.i-1 : p.i]
+	}
+	return ""
+}
+
+// parseString parses quoted string.
+func (p *selectorParser) parseString() (string, error) {
+	quote := p.s[p.i]
+	p.i++
+	var b strings.Builder
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch c {
+		case quote:
+			p.i++
+			return b.String(), nil
+		case '\\':
+			b.WriteString(p.parseEscape())
+		default:
+			b.WriteByte(c)
+			p.i++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func isIdentStart(s string, i int) bool {
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	if i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c == '_' || c == '\\' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '-' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' ||
+		'0' <= c && c <= '9' || c >= 0x80
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isElement(n *html.Node) bool {
+	return n.Type == html.ElementNode
+}
+
+func isDisabled(n *html.Node) bool {
+	_, ok := attr(n, "disabled")
+	return ok
+}
+
+// attr returns value of attribute and whether node has the attribute.
+func attr(node *html.Node, key string) (string, bool) {
+	for _, v := range node.Attr {
+		if v.Namespace == "" && v.Key == key {
+			return v.Val, true
+		}
+	}
+	return "", false
+}
+
+// includesWord reports whether whitespace separated list s includes word.
+func includesWord(s, word string) bool {
+	if word == "" {
+		return false
+	}
+	for _, w := range strings.Fields(s) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+func prevElementSibling(n *html.Node) *html.Node {
+	for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+func nextElementSibling(n *html.Node) *html.Node {
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
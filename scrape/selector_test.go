@@ -0,0 +1,130 @@
+package scrape
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const selectorTestHTML = `<!DOCTYPE html>
+<html>
+	<body>
+		<div class="article main" id="top">
+			<h1><a href="/title">Title</a><a name="anchor">Anchor</a></h1>
+			<p lang="en-US">first</p>
+			<p lang="en">second</p>
+			<p data-x="abc">third</p>
+			<span></span>
+		</div>
+		<div class="article">
+			<h2><a href="/sub">Sub</a></h2>
+		</div>
+		<ul>
+			<li>1</li><li>2</li><li>3</li><li>4</li><li>5</li>
+		</ul>
+		<form><input name="a" checked><input name="b" disabled></form>
+	</body>
+</html>`
+
+func selectText(t *testing.T, root *html.Node, selector string) []string {
+	t.Helper()
+	nodes, err := Select(root, selector)
+	assert.NoError(t, err, selector)
+	texts := make([]string, len(nodes))
+	for i, n := range nodes {
+		texts[i] = Text(n)
+		if texts[i] == "" {
+			texts[i] = "<" + n.Data + ">"
+		}
+	}
+	return texts
+}
+
+func TestSelect(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(selectorTestHTML))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		selector string
+		want     []string
+	}{
+		{"div.article > h1 a[href]", []string{"Title"}},
+		{"div.article a[href]", []string{"Title", "Sub"}},
+		{".article.main h1", []string{"Title Anchor"}},
+		{"#top > p", []string{"first", "second", "third"}},
+		{"DIV#top>P", []string{"first", "second", "third"}},
+		{"h1 + p", []string{"first"}},
+		{"h1 ~ p", []string{"first", "second", "third"}},
+		{"p ~ span", []string{"<span>"}},
+		{"a[name]", []string{"Anchor"}},
+		{`a[href="/sub"]`, []string{"Sub"}},
+		{"a[href^='/t']", []string{"Title"}},
+		{"a[href$=ub]", []string{"Sub"}},
+		{"a[href*=itl]", []string{"Title"}},
+		{"p[lang|=en]", []string{"first", "second"}},
+		{"div[class~=main]", []string{"Title Anchor first second third"}},
+		{"li:nth-child(2n+1)", []string{"1", "3", "5"}},
+		{"li:nth-child(odd)", []string{"1", "3", "5"}},
+		{"li:nth-child(even)", []string{"2", "4"}},
+		{"li:nth-child(-n+2)", []string{"1", "2"}},
+		{"li:nth-child(3)", []string{"3"}},
+		{"li:nth-last-child(1)", []string{"5"}},
+		{"li:first-child, li:last-child", []string{"1", "5"}},
+		{"#top p:nth-of-type(2)", []string{"second"}},
+		{"#top p:last-of-type", []string{"third"}},
+		{"h1 a:only-child", []string{}},
+		{"h2 a:only-child", []string{"Sub"}},
+		{"li:not(:nth-child(odd))", []string{"2", "4"}},
+		{"#top > :not(p, h1)", []string{"<span>"}},
+		{"span:empty", []string{"<span>"}},
+		{":root > body > ul > li:first-of-type", []string{"1"}},
+		{"input:checked", []string{"<input>"}},
+		{"input:disabled", []string{"<input>"}},
+		{"input:enabled, input:disabled", []string{"<input>", "<input>"}},
+		{"input:enabled[name=a]", []string{"<input>"}},
+		{"*[data-x]", []string{"third"}},
+		{`p[data-x="\61 bc"]`, []string{"third"}},
+	}
+
+	for _, tt := range testCases {
+		got := selectText(t, root, tt.selector)
+		assert.Equal(t, tt.want, got, tt.selector)
+	}
+}
+
+func TestSelectFirst(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(selectorTestHTML))
+	assert.NoError(t, err)
+
+	got, err := SelectFirst(root, "div.article a[href]")
+	assert.NoError(t, err)
+	assert.Equal(t, "/title", Attr(got, "href"))
+
+	got, err = SelectFirst(root, "table")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestCompileSelectorComposesWithMatcher(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(selectorTestHTML))
+	assert.NoError(t, err)
+
+	m := MustCompileSelector("div.article *")
+	byA := ByTag(atom.A)
+	got := FindAll(root, func(n *html.Node) bool {
+		return m(n) && byA(n)
+	})
+	assert.Equal(t, 3, len(got))
+}
+
+func TestCompileSelectorError(t *testing.T) {
+	for _, selector := range []string{
+		"", "div >", "a[href", "a[href=]", "li:nth-child(x)", ":unknown", "a,", "p:not(", "#", `a[href="x]`,
+	} {
+		_, err := CompileSelector(selector)
+		assert.Error(t, err, selector)
+	}
+}
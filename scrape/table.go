@@ -0,0 +1,213 @@
+package scrape
+
+import (
+	"strconv"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxColspan and maxRowspan limit spans as browsers do.
+const (
+	maxColspan = 1000
+	maxRowspan = 65534
+)
+
+// Table is a table as a grid of cell texts.
+// A cell spanning several columns or rows is repeated in each of them.
+type Table struct {
+	// Caption is the text of the caption element.
+	Caption string `json:"caption,omitempty"`
+	// Header is the rows in thead, or the first row if it consists of th cells only.
+	Header [][]string `json:"header,omitempty"`
+	// Rows is the body rows. Every row of Header and Rows has the same length.
+	Rows [][]string `json:"rows"`
+	// Node is the table element.
+	Node *html.Node `json:"-"`
+}
+
+// Tables returns every table in node in document order, including nested
+// tables. Text of a nested table is not included in the cell of the outer table.
+func Tables(node *html.Node) []*Table {
+	tables := make([]*Table, 0)
+	for _, n := range FindAll(node, ByTag(atom.Table)) {
+		tables = append(tables, NewTable(n))
+	}
+	return tables
+}
+
+// NewTable returns the grid of the table element.
+func NewTable(n *html.Node) *Table {
+	t := &Table{Node: n}
+	if c := Find(n, func(c *html.Node) bool {
+		return c.DataAtom == atom.Caption && closestTable(c) == n
+	}); c != nil {
+		t.Caption = cellText(c)
+	}
+
+	var grid [][]string
+	rows := tableRows(n)
+	headerRows := 0
+	for _, tr := range rows {
+		if tr.Parent.DataAtom == atom.Thead {
+			headerRows++
+		}
+	}
+
+	// Spans are clamped to the number of columns and rows of the table, so
+	// that large spans of a few cells do not make a huge grid. The number
+	// of columns is the largest number of cells in a row.
+	columns := 0
+	for _, tr := range rows {
+		if n := len(Children(tr, Or(ByTag(atom.Td), ByTag(atom.Th)))); n > columns {
+			columns = n
+		}
+	}
+
+	// pending holds cells spanning rows below, by column.
+	type span struct {
+		rows int
+		text string
+	}
+	pending := map[int]*span{}
+	for i, tr := range rows {
+		row := make([]string, 0)
+		consumed := map[int]bool{}
+		consume := func(col int) {
+			s := pending[col]
+			row = setCell(row, col, s.text)
+			consumed[col] = true
+			if s.rows--; s.rows == 0 {
+				delete(pending, col)
+			}
+		}
+
+		col := 0
+		allHeader := true
+		cells := Children(tr, Or(ByTag(atom.Td), ByTag(atom.Th)))
+		for _, cell := range cells {
+			for pending[col] != nil && !consumed[col] {
+				consume(col)
+				col++
+			}
+			if cell.DataAtom != atom.Th {
+				allHeader = false
+			}
+			text := cellText(cell)
+			colspan := spanAttr(cell, "colspan", maxColspan)
+			if max := columns - col; colspan > max {
+				colspan = max
+				if colspan < 1 {
+					colspan = 1
+				}
+			}
+			rowspan := spanAttr(cell, "rowspan", maxRowspan)
+			if max := len(rows) - i; rowspan > max {
+				rowspan = max
+			}
+			for j := 0; j < colspan; j++ {
+				row = setCell(row, col, text)
+				if rowspan > 1 {
+					pending[col] = &span{rowspan - 1, text}
+					consumed[col] = true
+				}
+				col++
+			}
+		}
+		// Cells spanning from above which are not reached by cells of this row.
+		for c := range pending {
+			if !consumed[c] {
+				consume(c)
+			}
+		}
+
+		// Without thead, the first row of th cells is the header.
+		if i == 0 && headerRows == 0 && len(cells) > 0 && allHeader {
+			headerRows = 1
+		}
+		grid = append(grid, row)
+	}
+
+	width := 0
+	for _, r := range grid {
+		if len(r) > width {
+			width = len(r)
+		}
+	}
+	for i, r := range grid {
+		for len(r) < width {
+			r = append(r, "")
+		}
+		grid[i] = r
+	}
+	if headerRows > 0 {
+		t.Header = grid[:headerRows]
+	}
+	t.Rows = grid[headerRows:]
+	return t
+}
+
+// tableRows returns rows of the table in the order of thead, tbody and tfoot,
+// without rows of nested tables.
+func tableRows(table *html.Node) []*html.Node {
+	var head, body, foot []*html.Node
+	for _, tr := range FindAll(table, ByTag(atom.Tr)) {
+		if closestTable(tr) != table {
+			continue
+		}
+		switch tr.Parent.DataAtom {
+		case atom.Thead:
+			head = append(head, tr)
+		case atom.Tfoot:
+			foot = append(foot, tr)
+		default:
+			body = append(body, tr)
+		}
+	}
+	rows := append(head, body...)
+	return append(rows, foot...)
+}
+
+// closestTable returns the nearest table element that contains n.
+func closestTable(n *html.Node) *html.Node {
+	if n.Parent == nil {
+		return nil
+	}
+	return Closest(n.Parent, ByTag(atom.Table))
+}
+
+// cellText returns text of the cell without nested tables.
+func cellText(cell *html.Node) string {
+	return Text(withoutTables(cell))
+}
+
+// withoutTables returns a copy of n without descendant table elements.
+func withoutTables(n *html.Node) *html.Node {
+	c := &html.Node{Type: n.Type, DataAtom: n.DataAtom, Data: n.Data}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.DataAtom == atom.Table {
+			continue
+		}
+		c.AppendChild(withoutTables(child))
+	}
+	return c
+}
+
+func spanAttr(cell *html.Node, key string, max int) int {
+	n, err := strconv.Atoi(Attr(cell, key))
+	if err != nil || n < 1 {
+		return 1
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+func setCell(row []string, col int, text string) []string {
+	for len(row) <= col {
+		row = append(row, "")
+	}
+	row[col] = text
+	return row
+}
@@ -0,0 +1,92 @@
+package scrape
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func parseTables(t *testing.T, s string) []*Table {
+	root, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Tables(root)
+}
+
+func TestTables(t *testing.T) {
+	tables := parseTables(t, `<table>
+<caption>Prices</caption>
+<thead><tr><th rowspan="2">Item</th><th colspan="2">Price</th></tr>
+<tr><th>USD</th><th>JPY</th></tr></thead>
+<tfoot><tr><td>Total</td><td>3</td><td>300</td></tr></tfoot>
+<tbody>
+<tr><td>A</td><td>1</td><td rowspan="2">100</td></tr>
+<tr><td>B</td><td>2</td></tr>
+</tbody>
+</table>`)
+
+	if assert.Len(t, tables, 1) {
+		assert.Equal(t, "Prices", tables[0].Caption)
+		assert.Equal(t, [][]string{{"Item", "Price", "Price"}, {"Item", "USD", "JPY"}}, tables[0].Header)
+		assert.Equal(t, [][]string{{"A", "1", "100"}, {"B", "2", "100"}, {"Total", "3", "300"}}, tables[0].Rows)
+	}
+}
+
+func TestTablesWithoutThead(t *testing.T) {
+	tables := parseTables(t, `
+<table><tr><th>Name</th><th>Age</th></tr><tr><td>Alice</td><td>20</td></tr><tr><td>Bob</td></tr></table>
+<table><tr><th>Name</th><td>Alice</td></tr><tr><th>Age</th><td>20</td></tr></table>`)
+
+	if assert.Len(t, tables, 2) {
+		assert.Equal(t, [][]string{{"Name", "Age"}}, tables[0].Header)
+		assert.Equal(t, [][]string{{"Alice", "20"}, {"Bob", ""}}, tables[0].Rows)
+		assert.Nil(t, tables[1].Header)
+		assert.Equal(t, [][]string{{"Name", "Alice"}, {"Age", "20"}}, tables[1].Rows)
+	}
+}
+
+func TestTablesNested(t *testing.T) {
+	tables := parseTables(t, `<table>
+<tr><td>outer <table><tr><td>inner 1</td><td>inner 2</td></tr></table></td><td>x</td></tr>
+</table>`)
+
+	if assert.Len(t, tables, 2) {
+		assert.Equal(t, [][]string{{"outer", "x"}}, tables[0].Rows)
+		assert.Equal(t, [][]string{{"inner 1", "inner 2"}}, tables[1].Rows)
+	}
+}
+
+func TestTablesSpans(t *testing.T) {
+	tables := parseTables(t, `<table>
+<tr><td rowspan="3">a</td><td>b</td><td rowspan="2">c</td></tr>
+<tr><td colspan="1">d</td></tr>
+<tr><td colspan="2">e</td></tr>
+<tr><td colspan="0">f</td><td rowspan="x">g</td></tr>
+</table>`)
+
+	if assert.Len(t, tables, 1) {
+		expected := [][]string{
+			{"a", "b", "c"},
+			{"a", "d", "c"},
+			{"a", "e", "e"},
+			{"f", "g", ""},
+		}
+		assert.Equal(t, expected, tables[0].Rows)
+	}
+}
+
+func TestTablesHugeSpans(t *testing.T) {
+	tables := parseTables(t, `<table>
+<tr><th colspan="1000">title</th></tr>
+<tr><td rowspan="65534" colspan="1000">a</td><td>b</td></tr>
+<tr><td>c</td></tr>
+</table>`)
+
+	if assert.Len(t, tables, 1) {
+		assert.Equal(t, [][]string{{"title", "title", ""}}, tables[0].Header)
+		assert.Equal(t, [][]string{{"a", "a", "b"}, {"a", "a", "c"}}, tables[0].Rows)
+	}
+}
@@ -0,0 +1,225 @@
+package scrape
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// TextOptions controls how TextWithOptions renders text.
+type TextOptions struct {
+	// BlockBreaks breaks lines at block elements and `<br>`, with a blank
+	// line between paragraphs, and separates table cells by tab.
+	// Without it, they are separated by a space.
+	BlockBreaks bool
+	// SkipHidden skips contents of script, style, noscript and template,
+	// and elements hidden by `hidden`, `aria-hidden="true"` or inline
+	// `display:none` and `visibility:hidden` styles.
+	SkipHidden bool
+	// KeepPre keeps white space and line breaks in `<pre>` and `<textarea>`.
+	KeepPre bool
+}
+
+// DefaultTextOptions renders text as a browser shows it.
+var DefaultTextOptions = TextOptions{
+	BlockBreaks: true,
+	SkipHidden:  true,
+	KeepPre:     true,
+}
+
+// TextWithOptions returns text of node rendered by opts.
+// White space is collapsed as in HTML, and non-breaking spaces are
+// written as spaces without being collapsed. Entities are decoded by the parser.
+func TextWithOptions(node *html.Node, opts TextOptions) string {
+	tr := &textRenderer{opts: opts}
+	tr.render(node)
+	return tr.String()
+}
+
+// textRenderer writes text with pending separators, so that separators
+// are written only between texts.
+type textRenderer struct {
+	opts TextOptions
+	// skip reports whether the element is skipped in addition to hidden ones.
+	skip func(*html.Node) bool
+	b    strings.Builder
+	// breaks is the number of line breaks to write before the next text.
+	breaks int
+	// sep is written before the next text when breaks is zero.
+	sep string
+}
+
+func (tr *textRenderer) String() string {
+	lines := strings.Split(tr.b.String(), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// write writes s after pending separators.
+func (tr *textRenderer) write(s string) {
+	if s == "" {
+		return
+	}
+	if tr.b.Len() > 0 {
+		if tr.breaks > 0 {
+			tr.b.WriteString(strings.Repeat("\n", tr.breaks))
+		} else {
+			tr.b.WriteString(tr.sep)
+		}
+	}
+	tr.breaks, tr.sep = 0, ""
+	tr.b.WriteString(s)
+}
+
+// space requests a space before the next text.
+func (tr *textRenderer) space() {
+	if tr.sep == "" {
+		tr.sep = " "
+	}
+}
+
+// lineBreak requests at least n line breaks before the next text.
+func (tr *textRenderer) lineBreak(n int) {
+	if !tr.opts.BlockBreaks {
+		tr.space()
+		return
+	}
+	if tr.breaks < n {
+		tr.breaks = n
+	}
+}
+
+func (tr *textRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		tr.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		tr.children(n)
+		return
+	}
+
+	if tr.opts.SkipHidden && isInvisible(n) || tr.skip != nil && tr.skip(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		if tr.opts.BlockBreaks {
+			if tr.b.Len() > 0 {
+				tr.b.WriteString(strings.Repeat("\n", tr.breaks))
+			}
+			tr.breaks, tr.sep = 1, ""
+		} else {
+			tr.space()
+		}
+		return
+	case atom.Pre, atom.Textarea:
+		if tr.opts.KeepPre {
+			tr.lineBreak(2)
+			tr.pre(n)
+			tr.lineBreak(2)
+			return
+		}
+	case atom.Td, atom.Th:
+		if tr.opts.BlockBreaks && prevElementSibling(n) != nil {
+			tr.sep = "\t"
+		} else {
+			tr.space()
+		}
+		tr.children(n)
+		tr.space()
+		return
+	}
+
+	breaks := blockBreaks(n)
+	if breaks > 0 {
+		tr.lineBreak(breaks)
+	}
+	tr.children(n)
+	if breaks > 0 {
+		tr.lineBreak(breaks)
+	}
+}
+
+func (tr *textRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		tr.render(c)
+	}
+}
+
+// text writes s with white space collapsed.
+func (tr *textRenderer) text(s string) {
+	for i, w := range strings.FieldsFunc(s, isHTMLSpace) {
+		if i > 0 || isHTMLSpace(rune(s[0])) {
+			tr.space()
+		}
+		tr.write(strings.Replace(w, "\u00a0", " ", -1))
+	}
+	if s != "" && isHTMLSpace(rune(s[len(s)-1])) {
+		tr.space()
+	}
+}
+
+// pre writes text of n as is. The first line break is removed as HTML does.
+func (tr *textRenderer) pre(n *html.Node) {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+		if n.DataAtom == atom.Br {
+			b.WriteByte('\n')
+		}
+		if tr.opts.SkipHidden && isInvisible(n) {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	s := strings.TrimPrefix(b.String(), "\n")
+	s = strings.TrimRight(s, "\n")
+	tr.write(strings.Replace(s, "\u00a0", " ", -1))
+}
+
+// isHTMLSpace reports whether r is ASCII white space in HTML.
+// Non-breaking space is not white space.
+func isHTMLSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
+
+// isInvisible reports whether the element is not rendered.
+func isInvisible(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template:
+		return true
+	case atom.Input:
+		return strings.EqualFold(Attr(n, "type"), "hidden")
+	}
+	return isHidden(n)
+}
+
+// blockBreaks returns the number of line breaks around the element.
+// It is 2 for paragraphs and 1 for other blocks.
+func blockBreaks(n *html.Node) int {
+	switch n.DataAtom {
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+		atom.Blockquote, atom.Pre, atom.Table, atom.Ul, atom.Ol, atom.Dl,
+		atom.Figure, atom.Hr, atom.Address, atom.Fieldset, atom.Details:
+		return 2
+	case atom.Title, atom.Tr, atom.Li, atom.Option, atom.Legend, atom.Summary:
+		return 1
+	}
+	if isBlock(n) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package scrape
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+const testTextPage = `<html><head><title>Title</title><style>p { color: red; }</style></head>
+<body>
+<h1>Heading</h1>
+<p>First   paragraph
+with <b>bold</b>,<i>italic</i> and&nbsp;&nbsp;entities &amp; &lt;tags&gt;.</p>
+<p>Line one<br>Line two</p>
+<script>var hidden = true;</script>
+<div hidden>hidden attribute</div>
+<div aria-hidden="true">aria hidden</div>
+<div style="display: none">display none</div>
+<ul><li>one</li><li>two</li></ul>
+<table><tr><th>Name</th><th>Age</th></tr><tr><td>Alice</td><td>20</td></tr></table>
+<pre>
+  keep
+    this</pre>
+<div>a<span>b</span> c</div>
+</body></html>`
+
+func parseText(t *testing.T) *html.Node {
+	root, err := html.Parse(strings.NewReader(testTextPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestTextWithOptions(t *testing.T) {
+	expected := "Title\n\n" +
+		"Heading\n\n" +
+		"First paragraph with bold,italic and  entities & <tags>.\n\n" +
+		"Line one\nLine two\n\n" +
+		"one\ntwo\n\n" +
+		"Name\tAge\nAlice\t20\n\n" +
+		"  keep\n    this\n\n" +
+		"ab c"
+	assert.Equal(t, expected, TextWithOptions(parseText(t), DefaultTextOptions))
+}
+
+func TestTextWithOptionsDisabled(t *testing.T) {
+	got := TextWithOptions(parseText(t), TextOptions{})
+	assert.Equal(t, "Title p { color: red; } Heading First paragraph with bold,italic and  entities & <tags>. "+
+		"Line one Line two var hidden = true; hidden attribute aria hidden display none one two "+
+		"Name Age Alice 20 keep this ab c", got)
+
+	got = TextWithOptions(parseText(t), TextOptions{SkipHidden: true})
+	assert.NotContains(t, got, "hidden")
+	assert.NotContains(t, got, "color")
+}
+
+func TestTextUnchanged(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(`<p>a<b>b</b></p><script>c</script>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "a b c", Text(root))
+}
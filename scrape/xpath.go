@@ -0,0 +1,1298 @@
+package scrape
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrInvalidXPath is the error thrown if XPath expression can not be parsed
+// or its result can not be converted.
+var ErrInvalidXPath = errors.New("Invalid XPath")
+
+// XPath returns nodes selected by XPath 1.0 expression.
+// It is an error if the result is not a node-set, or if it has attribute
+// nodes. Use XPathStrings to get values of attributes.
+func XPath(node *html.Node, expr string) ([]*html.Node, error) {
+	e, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return e.Nodes(node)
+}
+
+// XPathStrings returns string values of nodes selected by XPath 1.0 expression,
+// such as `//a/@href` or `//h1/text()`. If the result is not a node-set,
+// it returns the result converted to string.
+func XPathStrings(node *html.Node, expr string) ([]string, error) {
+	e, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return e.Strings(node), nil
+}
+
+// XPathString returns the result of XPath 1.0 expression converted to string,
+// such as `normalize-space(//h1)` or `count(//a)`. A node-set is converted to
+// the string value of its first node.
+func XPathString(node *html.Node, expr string) (string, error) {
+	e, err := CompileXPath(expr)
+	if err != nil {
+		return "", err
+	}
+	return e.Value(node), nil
+}
+
+// XPathExpr is a compiled XPath 1.0 expression.
+//
+// All axes, predicates, node tests, operators and the core function library
+// are supported except `id()`, `lang()` and variables. Element and attribute
+// names are matched case-insensitively, and namespace prefixes are ignored.
+type XPathExpr struct {
+	expr string
+	root xexpr
+}
+
+// CompileXPath parses XPath 1.0 expression.
+func CompileXPath(expr string) (*XPathExpr, error) {
+	tokens, err := lexXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &xpathParser{expr: expr, tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != xtEOF {
+		return nil, p.errorf("unexpected %q", p.peek().val)
+	}
+	return &XPathExpr{expr, root}, nil
+}
+
+// MustCompileXPath is like CompileXPath but panics if the expression
+// can not be parsed.
+func MustCompileXPath(expr string) *XPathExpr {
+	e, err := CompileXPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func (e *XPathExpr) String() string {
+	return e.expr
+}
+
+// Evaluate evaluates the expression with node as the context node.
+// The result is one of []*html.Node for a node-set without attributes,
+// []string for a node-set with attributes, string, float64 or bool.
+func (e *XPathExpr) Evaluate(node *html.Node) interface{} {
+	switch v := e.eval(node).(type) {
+	case xnodeset:
+		if v.hasAttr() {
+			return v.strings()
+		}
+		return v.nodes()
+	default:
+		return v
+	}
+}
+
+// Nodes returns selected nodes.
+func (e *XPathExpr) Nodes(node *html.Node) ([]*html.Node, error) {
+	ns, ok := e.eval(node).(xnodeset)
+	if !ok {
+		return nil, fmt.Errorf("%v: result is not a node-set: %s", ErrInvalidXPath, e.expr)
+	}
+	if ns.hasAttr() {
+		return nil, fmt.Errorf("%v: result has attribute nodes: %s", ErrInvalidXPath, e.expr)
+	}
+	return ns.nodes(), nil
+}
+
+// Strings returns string values of selected nodes.
+func (e *XPathExpr) Strings(node *html.Node) []string {
+	v := e.eval(node)
+	if ns, ok := v.(xnodeset); ok {
+		return ns.strings()
+	}
+	return []string{toXString(v)}
+}
+
+// Value returns the result converted to string.
+func (e *XPathExpr) Value(node *html.Node) string {
+	return toXString(e.eval(node))
+}
+
+func (e *XPathExpr) eval(node *html.Node) interface{} {
+	root := node
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	env := &xenv{root: root}
+	return e.root.eval(&xcontext{node: xnode{node, -1}, pos: 1, size: 1, env: env})
+}
+
+// xnode is a node of XPath data model. Attribute is represented by
+// the index of the attribute of the owner element.
+type xnode struct {
+	n    *html.Node
+	attr int
+}
+
+func (x xnode) isAttr() bool {
+	return x.attr >= 0
+}
+
+func (x xnode) name() string {
+	if x.isAttr() {
+		return x.n.Attr[x.attr].Key
+	}
+	if x.n.Type == html.ElementNode {
+		return x.n.Data
+	}
+	return ""
+}
+
+// stringValue returns string-value of the node defined by XPath.
+func (x xnode) stringValue() string {
+	if x.isAttr() {
+		return x.n.Attr[x.attr].Val
+	}
+	switch x.n.Type {
+	case html.TextNode, html.CommentNode:
+		return x.n.Data
+	}
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				b.WriteString(c.Data)
+			}
+			walk(c)
+		}
+	}
+	walk(x.n)
+	return b.String()
+}
+
+// xnodeset is a node-set in document order without duplicates.
+type xnodeset []xnode
+
+func (ns xnodeset) hasAttr() bool {
+	for _, x := range ns {
+		if x.isAttr() {
+			return true
+		}
+	}
+	return false
+}
+
+func (ns xnodeset) nodes() []*html.Node {
+	nodes := make([]*html.Node, len(ns))
+	for i, x := range ns {
+		nodes[i] = x.n
+	}
+	return nodes
+}
+
+func (ns xnodeset) strings() []string {
+	s := make([]string, len(ns))
+	for i, x := range ns {
+		s[i] = x.stringValue()
+	}
+	return s
+}
+
+type xenv struct {
+	root  *html.Node
+	order map[xnode]int
+}
+
+// sort sorts nodes in document order and removes duplicates.
+func (env *xenv) sort(nodes []xnode) xnodeset {
+	if env.order == nil {
+		env.order = map[xnode]int{}
+		i := 0
+		var walk func(*html.Node)
+		walk = func(n *html.Node) {
+			env.order[xnode{n, -1}] = i
+			i++
+			for a := range n.Attr {
+				env.order[xnode{n, a}] = i
+				i++
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+		walk(env.root)
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return env.order[nodes[i]] < env.order[nodes[j]]
+	})
+	result := nodes[:0]
+	for i, x := range nodes {
+		if i > 0 && x == nodes[i-1] {
+			continue
+		}
+		result = append(result, x)
+	}
+	return xnodeset(result)
+}
+
+type xcontext struct {
+	node xnode
+	pos  int
+	size int
+	env  *xenv
+}
+
+// xexpr is a node of the expression tree. eval returns one of
+// xnodeset, string, float64 and bool.
+type xexpr interface {
+	eval(ctx *xcontext) interface{}
+}
+
+type (
+	xliteral string
+	xnumber  float64
+	xnegate  struct{ e xexpr }
+	xbinary  struct {
+		op   string
+		l, r xexpr
+	}
+	xunion struct{ l, r xexpr }
+	xcall  struct {
+		name string
+		args []xexpr
+	}
+	// xpath is a location path, or a filter expression followed by steps.
+	xpath struct {
+		absolute bool
+		filter   xexpr
+		preds    []xexpr
+		steps    []*xstep
+	}
+	xstep struct {
+		axis  string
+		test  xnodetest
+		preds []xexpr
+	}
+	xnodetest struct {
+		// kind is "name", "node", "text", "comment" or "processing-instruction".
+		kind string
+		// name is local name of name test. "*" matches any name.
+		name string
+	}
+)
+
+func (e xliteral) eval(*xcontext) interface{} { return string(e) }
+func (e xnumber) eval(*xcontext) interface{}  { return float64(e) }
+func (e xnegate) eval(ctx *xcontext) interface{} {
+	return -toXNumber(e.e.eval(ctx))
+}
+
+func (e xunion) eval(ctx *xcontext) interface{} {
+	l, lok := e.l.eval(ctx).(xnodeset)
+	r, rok := e.r.eval(ctx).(xnodeset)
+	if !lok || !rok {
+		return xnodeset{}
+	}
+	nodes := make([]xnode, 0, len(l)+len(r))
+	nodes = append(append(nodes, l...), r...)
+	return ctx.env.sort(nodes)
+}
+
+func (e xbinary) eval(ctx *xcontext) interface{} {
+	switch e.op {
+	case "or":
+		return toXBool(e.l.eval(ctx)) || toXBool(e.r.eval(ctx))
+	case "and":
+		return toXBool(e.l.eval(ctx)) && toXBool(e.r.eval(ctx))
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compareX(e.op, e.l.eval(ctx), e.r.eval(ctx))
+	}
+
+	l, r := toXNumber(e.l.eval(ctx)), toXNumber(e.r.eval(ctx))
+	switch e.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "div":
+		return l / r
+	case "mod":
+		return math.Mod(l, r)
+	}
+	return math.NaN()
+}
+
+// compareX compares values by the rules of XPath 1.0.
+func compareX(op string, l, r interface{}) bool {
+	lns, lok := l.(xnodeset)
+	rns, rok := r.(xnodeset)
+	switch {
+	case lok && rok:
+		for _, a := range lns {
+			for _, b := range rns {
+				if compareAtomic(op, a.stringValue(), b.stringValue()) {
+					return true
+				}
+			}
+		}
+		return false
+	case lok:
+		if b, ok := r.(bool); ok {
+			return compareAtomic(op, len(lns) > 0, b)
+		}
+		for _, a := range lns {
+			if compareAtomic(op, convertLike(a.stringValue(), r), r) {
+				return true
+			}
+		}
+		return false
+	case rok:
+		if b, ok := l.(bool); ok {
+			return compareAtomic(op, b, len(rns) > 0)
+		}
+		for _, b := range rns {
+			if compareAtomic(op, l, convertLike(b.stringValue(), l)) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtomic(op, l, r)
+}
+
+// convertLike converts string value of a node to the type of v.
+func convertLike(s string, v interface{}) interface{} {
+	if _, ok := v.(float64); ok {
+		return toXNumber(s)
+	}
+	return s
+}
+
+// compareAtomic compares string, float64 or bool values.
+func compareAtomic(op string, l, r interface{}) bool {
+	if op == "=" || op == "!=" {
+		var eq bool
+		_, lb := l.(bool)
+		_, rb := r.(bool)
+		_, ln := l.(float64)
+		_, rn := r.(float64)
+		switch {
+		case lb || rb:
+			eq = toXBool(l) == toXBool(r)
+		case ln || rn:
+			eq = toXNumber(l) == toXNumber(r)
+		default:
+			eq = toXString(l) == toXString(r)
+		}
+		return eq == (op == "=")
+	}
+
+	a, b := toXNumber(l), toXNumber(r)
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func (e *xpath) eval(ctx *xcontext) interface{} {
+	var ns xnodeset
+	switch {
+	case e.absolute:
+		ns = xnodeset{{ctx.env.root, -1}}
+	case e.filter != nil:
+		v, ok := e.filter.eval(ctx).(xnodeset)
+		if !ok {
+			return xnodeset{}
+		}
+		ns = v
+		for _, pred := range e.preds {
+			ns = filterX(ns, pred, ctx.env)
+		}
+	default:
+		ns = xnodeset{ctx.node}
+	}
+
+	for _, st := range e.steps {
+		nodes := make([]xnode, 0)
+		for _, x := range ns {
+			candidates := make(xnodeset, 0)
+			for _, c := range axisX(x, st.axis) {
+				if st.test.match(c, st.axis) {
+					candidates = append(candidates, c)
+				}
+			}
+			for _, pred := range st.preds {
+				candidates = filterX(candidates, pred, ctx.env)
+			}
+			nodes = append(nodes, candidates...)
+		}
+		ns = ctx.env.sort(nodes)
+	}
+	return ns
+}
+
+// filterX filters nodes by predicate. Position is the index in nodes.
+func filterX(nodes xnodeset, pred xexpr, env *xenv) xnodeset {
+	result := make(xnodeset, 0, len(nodes))
+	for i, x := range nodes {
+		v := pred.eval(&xcontext{node: x, pos: i + 1, size: len(nodes), env: env})
+		if n, ok := v.(float64); ok {
+			if n == float64(i+1) {
+				result = append(result, x)
+			}
+			continue
+		}
+		if toXBool(v) {
+			result = append(result, x)
+		}
+	}
+	return result
+}
+
+func (t xnodetest) match(x xnode, axis string) bool {
+	switch t.kind {
+	case "node":
+		return true
+	case "text":
+		return !x.isAttr() && x.n.Type == html.TextNode
+	case "comment":
+		return !x.isAttr() && x.n.Type == html.CommentNode
+	case "processing-instruction":
+		return false
+	}
+	// Principal node type of attribute axis is attribute, and element for others.
+	if axis == "attribute" {
+		if !x.isAttr() {
+			return false
+		}
+	} else if x.isAttr() || x.n.Type != html.ElementNode {
+		return false
+	}
+	return t.name == "*" || strings.EqualFold(t.name, x.name())
+}
+
+// axisX returns nodes on the axis in axis order,
+// i.e. reverse document order for reverse axes.
+func axisX(x xnode, axis string) []xnode {
+	nodes := make([]xnode, 0)
+	n := x.n
+	switch axis {
+	case "self":
+		nodes = append(nodes, x)
+	case "attribute":
+		if !x.isAttr() && n.Type == html.ElementNode {
+			for i := range n.Attr {
+				nodes = append(nodes, xnode{n, i})
+			}
+		}
+	case "child":
+		if !x.isAttr() {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if isXNode(c) {
+					nodes = append(nodes, xnode{c, -1})
+				}
+			}
+		}
+	case "descendant", "descendant-or-self":
+		if axis == "descendant-or-self" {
+			nodes = append(nodes, x)
+		}
+		if !x.isAttr() {
+			nodes = appendDescendants(nodes, n)
+		}
+	case "parent":
+		if x.isAttr() {
+			nodes = append(nodes, xnode{n, -1})
+		} else if n.Parent != nil {
+			nodes = append(nodes, xnode{n.Parent, -1})
+		}
+	case "ancestor", "ancestor-or-self":
+		if axis == "ancestor-or-self" {
+			nodes = append(nodes, x)
+		}
+		if x.isAttr() {
+			nodes = append(nodes, xnode{n, -1})
+		}
+		for a := n.Parent; a != nil; a = a.Parent {
+			nodes = append(nodes, xnode{a, -1})
+		}
+	case "following-sibling":
+		if !x.isAttr() {
+			for s := n.NextSibling; s != nil; s = s.NextSibling {
+				if isXNode(s) {
+					nodes = append(nodes, xnode{s, -1})
+				}
+			}
+		}
+	case "preceding-sibling":
+		if !x.isAttr() {
+			for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+				if isXNode(s) {
+					nodes = append(nodes, xnode{s, -1})
+				}
+			}
+		}
+	case "following":
+		if x.isAttr() {
+			nodes = appendDescendants(nodes, n)
+		}
+		for a := n; a != nil; a = a.Parent {
+			for s := a.NextSibling; s != nil; s = s.NextSibling {
+				if isXNode(s) {
+					nodes = append(nodes, xnode{s, -1})
+					nodes = appendDescendants(nodes, s)
+				}
+			}
+		}
+	case "preceding":
+		for a := n; a != nil; a = a.Parent {
+			for s := a.PrevSibling; s != nil; s = s.PrevSibling {
+				if !isXNode(s) {
+					continue
+				}
+				subtree := appendDescendants([]xnode{{s, -1}}, s)
+				for i := len(subtree) - 1; i >= 0; i-- {
+					nodes = append(nodes, subtree[i])
+				}
+			}
+		}
+	}
+	return nodes
+}
+
+func appendDescendants(nodes []xnode, n *html.Node) []xnode {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isXNode(c) {
+			nodes = append(nodes, xnode{c, -1})
+			nodes = appendDescendants(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// isXNode reports whether n is a node of XPath data model.
+func isXNode(n *html.Node) bool {
+	switch n.Type {
+	case html.ElementNode, html.TextNode, html.CommentNode, html.DocumentNode:
+		return true
+	}
+	return false
+}
+
+// xfunctions has the number of arguments of each function, minimum and maximum.
+// Maximum -1 means variable arguments.
+var xfunctions = map[string][2]int{
+	"last": {0, 0}, "position": {0, 0}, "count": {1, 1},
+	"local-name": {0, 1}, "name": {0, 1}, "namespace-uri": {0, 1},
+	"string": {0, 1}, "concat": {2, -1}, "starts-with": {2, 2}, "ends-with": {2, 2},
+	"contains": {2, 2}, "substring-before": {2, 2}, "substring-after": {2, 2},
+	"substring": {2, 3}, "string-length": {0, 1}, "normalize-space": {0, 1},
+	"translate": {3, 3}, "boolean": {1, 1}, "not": {1, 1}, "true": {0, 0},
+	"false": {0, 0}, "number": {0, 1}, "sum": {1, 1}, "floor": {1, 1},
+	"ceiling": {1, 1}, "round": {1, 1},
+}
+
+func (e xcall) eval(ctx *xcontext) interface{} {
+	arg := func(i int) interface{} {
+		return e.args[i].eval(ctx)
+	}
+	// str returns i-th argument as string, or string-value of the context node.
+	str := func(i int) string {
+		if i < len(e.args) {
+			return toXString(arg(i))
+		}
+		return ctx.node.stringValue()
+	}
+	// first returns the first node of the argument, or the context node.
+	first := func() (xnode, bool) {
+		if len(e.args) == 0 {
+			return ctx.node, true
+		}
+		ns, ok := arg(0).(xnodeset)
+		if !ok || len(ns) == 0 {
+			return xnode{}, false
+		}
+		return ns[0], true
+	}
+
+	switch e.name {
+	case "last":
+		return float64(ctx.size)
+	case "position":
+		return float64(ctx.pos)
+	case "count":
+		ns, _ := arg(0).(xnodeset)
+		return float64(len(ns))
+	case "local-name", "name":
+		if x, ok := first(); ok {
+			return x.name()
+		}
+		return ""
+	case "namespace-uri":
+		return ""
+	case "string":
+		return str(0)
+	case "concat":
+		var b strings.Builder
+		for i := range e.args {
+			b.WriteString(str(i))
+		}
+		return b.String()
+	case "starts-with":
+		return strings.HasPrefix(str(0), str(1))
+	case "ends-with":
+		return strings.HasSuffix(str(0), str(1))
+	case "contains":
+		return strings.Contains(str(0), str(1))
+	case "substring-before":
+		s, sep := str(0), str(1)
+		if i := strings.Index(s, sep); i >= 0 {
+			return s[:i]
+		}
+		return ""
+	case "substring-after":
+		s, sep := str(0), str(1)
+		if i := strings.Index(s, sep); i >= 0 {
+			return s[i+len(sep):]
+		}
+		return ""
+	case "substring":
+		runes := []rune(str(0))
+		start := roundX(toXNumber(arg(1)))
+		end := math.Inf(1)
+		if len(e.args) == 3 {
+			end = start + roundX(toXNumber(arg(2)))
+		}
+		var b strings.Builder
+		for i, r := range runes {
+			if p := float64(i + 1); p >= start && p < end {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	case "string-length":
+		return float64(len([]rune(str(0))))
+	case "normalize-space":
+		return strings.Join(strings.Fields(str(0)), " ")
+	case "translate":
+		from, to := []rune(str(1)), []rune(str(2))
+		return strings.Map(func(r rune) rune {
+			for i, f := range from {
+				if f == r {
+					if i < len(to) {
+						return to[i]
+					}
+					return -1
+				}
+			}
+			return r
+		}, str(0))
+	case "boolean":
+		return toXBool(arg(0))
+	case "not":
+		return !toXBool(arg(0))
+	case "true":
+		return true
+	case "false":
+		return false
+	case "number":
+		if len(e.args) == 0 {
+			return toXNumber(ctx.node.stringValue())
+		}
+		return toXNumber(arg(0))
+	case "sum":
+		ns, _ := arg(0).(xnodeset)
+		sum := 0.0
+		for _, x := range ns {
+			sum += toXNumber(x.stringValue())
+		}
+		return sum
+	case "floor":
+		return math.Floor(toXNumber(arg(0)))
+	case "ceiling":
+		return math.Ceil(toXNumber(arg(0)))
+	case "round":
+		return roundX(toXNumber(arg(0)))
+	}
+	return math.NaN()
+}
+
+// roundX rounds half up as XPath round().
+func roundX(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	return math.Floor(f + 0.5)
+}
+
+func toXString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		case v == math.Trunc(v) && math.Abs(v) < 1e15:
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case xnodeset:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].stringValue()
+	}
+	return ""
+}
+
+func toXNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		s := strings.TrimSpace(v)
+		if !isXNumber(s) {
+			return math.NaN()
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return f
+	case xnodeset:
+		return toXNumber(toXString(v))
+	}
+	return math.NaN()
+}
+
+// isXNumber reports whether s matches `-? (Digits ('.' Digits?)? | '.' Digits)`.
+func isXNumber(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	digits, dot := 0, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case '0' <= c && c <= '9':
+			digits++
+		case c == '.' && !dot:
+			dot = true
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+func toXBool(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	case xnodeset:
+		return len(v) > 0
+	}
+	return false
+}
+
+type xtokenKind int
+
+const (
+	xtEOF xtokenKind = iota
+	xtNumber
+	xtLiteral
+	// xtName is a name test, such as `a`, `*` and `svg:*`.
+	xtName
+	// xtOperator is an operator, including `and`, `or`, `mod`, `div` and multiply `*`.
+	xtOperator
+	xtFunction
+	xtNodeType
+	xtAxis
+	// xtPunct is one of `(`, `)`, `[`, `]`, `.`, `..`, `@`, `,` and `::`.
+	xtPunct
+)
+
+type xtoken struct {
+	kind xtokenKind
+	val  string
+	pos  int
+}
+
+func lexXPath(expr string) ([]xtoken, error) {
+	tokens := make([]xtoken, 0)
+	// operatorContext reports whether the next `*` or name is an operator.
+	operatorContext := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		prev := tokens[len(tokens)-1]
+		switch prev.kind {
+		case xtOperator:
+			return false
+		case xtPunct:
+			return prev.val == ")" || prev.val == "]" || prev.val == "." || prev.val == ".."
+		}
+		return true
+	}
+
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		start := i
+		switch {
+		case isSpace(c):
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("%v: unterminated string at %d in %q", ErrInvalidXPath, i, expr)
+			}
+			tokens = append(tokens, xtoken{xtLiteral, expr[i+1 : i+1+end], start})
+			i += end + 2
+			continue
+		case '0' <= c && c <= '9' || c == '.' && i+1 < len(expr) && '0' <= expr[i+1] && expr[i+1] <= '9':
+			for i < len(expr) && ('0' <= expr[i] && expr[i] <= '9' || expr[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, xtoken{xtNumber, expr[start:i], start})
+			continue
+		case c == '.':
+			if strings.HasPrefix(expr[i:], "..") {
+				i += 2
+			} else {
+				i++
+			}
+			tokens = append(tokens, xtoken{xtPunct, expr[start:i], start})
+			continue
+		case strings.IndexByte("()[]@,", c) >= 0:
+			i++
+			tokens = append(tokens, xtoken{xtPunct, expr[start:i], start})
+			continue
+		case c == ':' && strings.HasPrefix(expr[i:], "::"):
+			i += 2
+			tokens = append(tokens, xtoken{xtPunct, "::", start})
+			continue
+		case c == '*':
+			i++
+			if operatorContext() {
+				tokens = append(tokens, xtoken{xtOperator, "*", start})
+			} else {
+				tokens = append(tokens, xtoken{xtName, "*", start})
+			}
+			continue
+		case c == '$':
+			return nil, fmt.Errorf("%v: variables are not supported at %d in %q", ErrInvalidXPath, i, expr)
+		}
+
+		for _, op := range []string{"//", "!=", "<=", ">=", "/", "|", "+", "-", "=", "<", ">"} {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, xtoken{xtOperator, op, start})
+				i += len(op)
+				break
+			}
+		}
+		if i > start {
+			continue
+		}
+
+		if !isXNameStart(c) {
+			return nil, fmt.Errorf("%v: unexpected %q at %d in %q", ErrInvalidXPath, c, i, expr)
+		}
+		for i < len(expr) && isXNameChar(expr[i]) {
+			i++
+		}
+		// QName or prefix:*
+		if i+1 < len(expr) && expr[i] == ':' && expr[i+1] != ':' {
+			i++
+			if expr[i] == '*' {
+				i++
+			} else {
+				for i < len(expr) && isXNameChar(expr[i]) {
+					i++
+				}
+			}
+		}
+		name := expr[start:i]
+
+		if operatorContext() {
+			switch name {
+			case "and", "or", "mod", "div":
+				tokens = append(tokens, xtoken{xtOperator, name, start})
+				continue
+			}
+			return nil, fmt.Errorf("%v: unexpected %q at %d in %q", ErrInvalidXPath, name, start, expr)
+		}
+
+		next := i
+		for next < len(expr) && isSpace(expr[next]) {
+			next++
+		}
+		switch {
+		case strings.HasPrefix(expr[next:], "::"):
+			tokens = append(tokens, xtoken{xtAxis, name, start})
+		case strings.HasPrefix(expr[next:], "("):
+			switch name {
+			case "node", "text", "comment", "processing-instruction":
+				tokens = append(tokens, xtoken{xtNodeType, name, start})
+			default:
+				tokens = append(tokens, xtoken{xtFunction, name, start})
+			}
+		default:
+			tokens = append(tokens, xtoken{xtName, name, start})
+		}
+	}
+	tokens = append(tokens, xtoken{xtEOF, "", len(expr)})
+	return tokens, nil
+}
+
+func isXNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
+
+func isXNameChar(c byte) bool {
+	return isXNameStart(c) || c == '-' || c == '.' || '0' <= c && c <= '9'
+}
+
+var xaxes = map[string]bool{
+	"ancestor": true, "ancestor-or-self": true, "attribute": true, "child": true,
+	"descendant": true, "descendant-or-self": true, "following": true,
+	"following-sibling": true, "namespace": true, "parent": true,
+	"preceding": true, "preceding-sibling": true, "self": true,
+}
+
+type xpathParser struct {
+	expr   string
+	tokens []xtoken
+	i      int
+}
+
+func (p *xpathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%v: %s at %d in %q", ErrInvalidXPath, fmt.Sprintf(format, args...), p.peek().pos, p.expr)
+}
+
+func (p *xpathParser) peek() xtoken {
+	return p.tokens[p.i]
+}
+
+func (p *xpathParser) next() xtoken {
+	t := p.tokens[p.i]
+	if t.kind != xtEOF {
+		p.i++
+	}
+	return t
+}
+
+// is reports whether the next token is kind and one of values.
+func (p *xpathParser) is(kind xtokenKind, values ...string) bool {
+	t := p.peek()
+	if t.kind != kind {
+		return false
+	}
+	for _, v := range values {
+		if t.val == v {
+			return true
+		}
+	}
+	return len(values) == 0
+}
+
+// back puts t back to the token stream.
+func (p *xpathParser) back(t xtoken) {
+	if t.kind != xtEOF {
+		p.i--
+	}
+}
+
+func (p *xpathParser) expect(kind xtokenKind, value string) error {
+	if !p.is(kind, value) {
+		return p.errorf("expected %q", value)
+	}
+	p.next()
+	return nil
+}
+
+func (p *xpathParser) parseExpr() (xexpr, error) {
+	return p.parseBinary(0)
+}
+
+// xprecedence is the list of binary operators from the lowest precedence.
+var xprecedence = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "div", "mod"},
+}
+
+func (p *xpathParser) parseBinary(level int) (xexpr, error) {
+	if level == len(xprecedence) {
+		return p.parseUnary()
+	}
+	l, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.is(xtOperator, xprecedence[level]...) {
+		op := p.next().val
+		r, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = xbinary{op, l, r}
+	}
+	return l, nil
+}
+
+func (p *xpathParser) parseUnary() (xexpr, error) {
+	if p.is(xtOperator, "-") {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return xnegate{e}, nil
+	}
+	l, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(xtOperator, "|") {
+		p.next()
+		r, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		l = xunion{l, r}
+	}
+	return l, nil
+}
+
+func (p *xpathParser) parsePath() (xexpr, error) {
+	path := new(xpath)
+	switch {
+	case p.is(xtOperator, "/"):
+		p.next()
+		path.absolute = true
+		if !p.startsStep() {
+			return path, nil
+		}
+	case p.is(xtOperator, "//"):
+		p.next()
+		path.absolute = true
+		path.steps = append(path.steps, descendantOrSelfStep())
+	case p.startsStep():
+	default:
+		filter, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		path.filter = filter
+		for p.is(xtPunct, "[") {
+			pred, err := p.parsePredicate()
+			if err != nil {
+				return nil, err
+			}
+			path.preds = append(path.preds, pred)
+		}
+		if !p.is(xtOperator, "/", "//") {
+			if len(path.preds) == 0 {
+				return filter, nil
+			}
+			return path, nil
+		}
+		if p.next().val == "//" {
+			path.steps = append(path.steps, descendantOrSelfStep())
+		}
+	}
+
+	for {
+		st, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, st)
+		if !p.is(xtOperator, "/", "//") {
+			return path, nil
+		}
+		if p.next().val == "//" {
+			path.steps = append(path.steps, descendantOrSelfStep())
+		}
+	}
+}
+
+func descendantOrSelfStep() *xstep {
+	return &xstep{axis: "descendant-or-self", test: xnodetest{kind: "node"}}
+}
+
+func (p *xpathParser) startsStep() bool {
+	t := p.peek()
+	switch t.kind {
+	case xtName, xtAxis, xtNodeType:
+		return true
+	case xtPunct:
+		return t.val == "." || t.val == ".." || t.val == "@"
+	}
+	return false
+}
+
+func (p *xpathParser) parseStep() (*xstep, error) {
+	switch {
+	case p.is(xtPunct, "."):
+		p.next()
+		return &xstep{axis: "self", test: xnodetest{kind: "node"}}, nil
+	case p.is(xtPunct, ".."):
+		p.next()
+		return &xstep{axis: "parent", test: xnodetest{kind: "node"}}, nil
+	}
+
+	st := &xstep{axis: "child"}
+	switch {
+	case p.is(xtPunct, "@"):
+		p.next()
+		st.axis = "attribute"
+	case p.is(xtAxis):
+		st.axis = p.next().val
+		if !xaxes[st.axis] {
+			return nil, p.errorf("unknown axis %q", st.axis)
+		}
+		if err := p.expect(xtPunct, "::"); err != nil {
+			return nil, err
+		}
+	}
+
+	switch t := p.next(); t.kind {
+	case xtName:
+		name := t.val
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name = name[i+1:]
+		}
+		st.test = xnodetest{kind: "name", name: name}
+	case xtNodeType:
+		st.test = xnodetest{kind: t.val}
+		if err := p.expect(xtPunct, "("); err != nil {
+			return nil, err
+		}
+		if t.val == "processing-instruction" && p.is(xtLiteral) {
+			p.next()
+		}
+		if err := p.expect(xtPunct, ")"); err != nil {
+			return nil, err
+		}
+	default:
+		p.back(t)
+		return nil, p.errorf("expected node test")
+	}
+
+	for p.is(xtPunct, "[") {
+		pred, err := p.parsePredicate()
+		if err != nil {
+			return nil, err
+		}
+		st.preds = append(st.preds, pred)
+	}
+	return st, nil
+}
+
+func (p *xpathParser) parsePredicate() (xexpr, error) {
+	p.next() // [
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(xtPunct, "]"); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (p *xpathParser) parsePrimary() (xexpr, error) {
+	t := p.next()
+	switch {
+	case t.kind == xtLiteral:
+		return xliteral(t.val), nil
+	case t.kind == xtNumber:
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			p.back(t)
+			return nil, p.errorf("invalid number %q", t.val)
+		}
+		return xnumber(f), nil
+	case t.kind == xtPunct && t.val == "(":
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(xtPunct, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case t.kind == xtFunction:
+		arity, ok := xfunctions[t.val]
+		if !ok {
+			p.back(t)
+			return nil, p.errorf("unknown function %q", t.val)
+		}
+		p.next() // (
+		call := xcall{name: t.val}
+		for !p.is(xtPunct, ")") {
+			if len(call.args) > 0 {
+				if err := p.expect(xtPunct, ","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		p.next() // )
+		if len(call.args) < arity[0] || arity[1] >= 0 && len(call.args) > arity[1] {
+			return nil, p.errorf("wrong number of arguments for %s()", t.val)
+		}
+		return call, nil
+	}
+	p.back(t)
+	if t.kind == xtEOF {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return nil, p.errorf("unexpected %q", t.val)
+}
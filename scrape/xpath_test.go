@@ -0,0 +1,186 @@
+package scrape
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+const xpathTestHTML = `<!DOCTYPE html>
+<html>
+	<body>
+		<div class="product" id="p1">
+			<h2>  Blue
+				Widget </h2>
+			<span class="price">10</span>
+			<a href="/p/1" rel="nofollow">detail</a>
+		</div>
+		<div class="product sale" id="p2">
+			<h2>Red Widget</h2>
+			<span class="price">25</span>
+			<a href="/p/2">detail</a>
+			<!-- comment -->
+		</div>
+		<ul><li>1</li><li>2</li><li>3</li></ul>
+	</body>
+</html>`
+
+func TestXPath(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(xpathTestHTML))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		expr string
+		want []string
+	}{
+		{"//h2", []string{"h2", "h2"}},
+		{"/html/body/div", []string{"div", "div"}},
+		{"//div[@id='p2']/h2", []string{"h2"}},
+		{"//div[contains(@class, 'sale')]", []string{"div"}},
+		{"//li[2]", []string{"li"}},
+		{"//li[last()]/..", []string{"ul"}},
+		{"//span[@class='price'][. > 20]/parent::div", []string{"div"}},
+		{"//h2/following-sibling::*", []string{"span", "a", "span", "a"}},
+		{"//a/preceding-sibling::span", []string{"span", "span"}},
+		{"//a/ancestor::*", []string{"html", "body", "div", "div"}},
+		{"(//li)[1] | (//li)[3]", []string{"li", "li"}},
+		{"//div[2]/comment()", []string{""}},
+		{"//div[not(@class='product')]", []string{"div"}},
+		{"//DIV[@ID='p1']", []string{"div"}},
+		{"//ul/li/following::*", []string{"li", "li"}},
+		{"//ul/preceding::h2", []string{"h2", "h2"}},
+		{"//*[self::h2 or self::ul]", []string{"h2", "h2", "ul"}},
+		{"//div[count(a[@rel]) = 1]", []string{"div"}},
+		{"//li[position() >= 2 and position() <= 3]", []string{"li", "li"}},
+		{"//body/descendant-or-self::ul", []string{"ul"}},
+		{"//li[. = 2]", []string{"li"}},
+	}
+	for _, tt := range testCases {
+		nodes, err := XPath(root, tt.expr)
+		assert.NoError(t, err, tt.expr)
+		got := make([]string, len(nodes))
+		for i, n := range nodes {
+			got[i] = n.Data
+			if n.Type == html.CommentNode {
+				got[i] = ""
+			}
+		}
+		assert.Equal(t, tt.want, got, tt.expr)
+	}
+}
+
+func TestXPathStrings(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(xpathTestHTML))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		expr string
+		want []string
+	}{
+		{"//a/@href", []string{"/p/1", "/p/2"}},
+		{"//div[2]/h2/text()", []string{"Red Widget"}},
+		{"//div/@*[name()='id']", []string{"p1", "p2"}},
+		{"//li/text()", []string{"1", "2", "3"}},
+		{"count(//li)", []string{"3"}},
+	}
+	for _, tt := range testCases {
+		got, err := XPathStrings(root, tt.expr)
+		assert.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, got, tt.expr)
+	}
+}
+
+func TestXPathString(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(xpathTestHTML))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		expr string
+		want string
+	}{
+		{"normalize-space(//h2)", "Blue Widget"},
+		{"normalize-space(//div[@id='p2']/h2/text())", "Red Widget"},
+		{"sum(//span[@class='price'])", "35"},
+		{"sum(//span) div count(//span)", "17.5"},
+		{"7 mod 3", "1"},
+		{"-(1 + 2) * 2", "-6"},
+		{"1 div 0", "Infinity"},
+		{"number('abc')", "NaN"},
+		{"concat('a', 'b', 'c')", "abc"},
+		{"substring('12345', 2, 3)", "234"},
+		{"substring('12345', 1.5, 2.6)", "234"},
+		{"substring-before('1999/04/01', '/')", "1999"},
+		{"substring-after('1999/04/01', '/')", "04/01"},
+		{"translate('bar', 'abc', 'ABC')", "BAr"},
+		{"translate('--aaa--', 'abc-', 'ABC')", "AAA"},
+		{"string-length('abc')", "3"},
+		{"starts-with(//a/@href, '/p')", "true"},
+		{"boolean(//table)", "false"},
+		{"round(2.5) + floor(1.7) + ceiling(1.2)", "6"},
+		{"//a[@rel='nofollow']/@href", "/p/1"},
+		{"local-name(//body/*[1])", "div"},
+		{"string(//li[3])", "3"},
+		{"//li = 3", "true"},
+		{"//li != 1", "true"},
+		{"//li > 3", "false"},
+		{"'' = false()", "true"},
+	}
+	for _, tt := range testCases {
+		got, err := XPathString(root, tt.expr)
+		assert.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, got, tt.expr)
+	}
+}
+
+func TestXPathRelativeToNode(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(xpathTestHTML))
+	assert.NoError(t, err)
+
+	products, err := XPath(root, "//div[@class]")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(products))
+
+	e := MustCompileXPath("normalize-space(h2)")
+	assert.Equal(t, "Blue Widget", e.Value(products[0]))
+	assert.Equal(t, "Red Widget", e.Value(products[1]))
+
+	price, err := XPathString(products[1], "span[@class='price']")
+	assert.NoError(t, err)
+	assert.Equal(t, "25", price)
+
+	// Absolute path starts from the document root.
+	nodes, err := XPath(products[1], "/html/body/ul")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(nodes))
+}
+
+func TestXPathEvaluate(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(xpathTestHTML))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3.0, MustCompileXPath("count(//li)").Evaluate(root))
+	assert.Equal(t, true, MustCompileXPath("//li = 2").Evaluate(root))
+	assert.Equal(t, []string{"p1", "p2"}, MustCompileXPath("//div/@id").Evaluate(root))
+	nodes, ok := MustCompileXPath("//li").Evaluate(root).([]*html.Node)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(nodes))
+}
+
+func TestXPathError(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(xpathTestHTML))
+	assert.NoError(t, err)
+
+	for _, expr := range []string{
+		"", "//", "//a[", "//a]", "foo()", "count()", "$var", "//a/@", "'unterminated", "bogus::a", "1 +",
+	} {
+		_, err := CompileXPath(expr)
+		assert.Error(t, err, expr)
+	}
+
+	_, err = XPath(root, "count(//a)")
+	assert.Error(t, err)
+	_, err = XPath(root, "//a/@href")
+	assert.Error(t, err)
+}
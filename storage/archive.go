@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greytabby/grawl/crawler"
+)
+
+// ErrUnknownArchiveFormat is the error thrown if the archive format
+// can not be decided from the file name.
+var ErrUnknownArchiveFormat = errors.New("Unknown archive format")
+
+// archiveWriter writes files to an archive.
+type archiveWriter interface {
+	writeFile(name string, body []byte, modTime time.Time) error
+	// Close writes the trailer of the archive.
+	Close() error
+}
+
+// ArchiveStorage streams crawl results into an archive with the same
+// path layout as FileStorage. Nothing is written to disk but the archive itself.
+// Close must be called to finalize the archive.
+type ArchiveStorage struct {
+	// WriteMeta enables writing metadata of each saved file to
+	// `<file>.meta.json` and `manifest.jsonl`.
+	WriteMeta bool
+	// OutputFormat is the format of saved pages, FormatRaw or FormatMarkdown.
+	// By default, pages are saved as fetched.
+	OutputFormat string
+	// Tables is the format of tables extracted from HTML pages, TablesCSV
+	// or TablesJSON. By default, tables are not extracted.
+	Tables string
+	// SkipNoIndex skips pages with noindex robots directive.
+	SkipNoIndex bool
+
+	aw       archiveWriter
+	closer   io.Closer
+	index    *pathIndex
+	indexBuf bytes.Buffer
+	manifest bytes.Buffer
+	mux      sync.Mutex
+}
+
+// NewTarGzStorage returns `*ArchiveStorage` that writes a gzip compressed tar archive to w.
+func NewTarGzStorage(w io.Writer) *ArchiveStorage {
+	gw := gzip.NewWriter(w)
+	return newArchiveStorage(&tarGzWriter{gw, tar.NewWriter(gw)})
+}
+
+// NewZipStorage returns `*ArchiveStorage` that writes a zip archive to w.
+func NewZipStorage(w io.Writer) *ArchiveStorage {
+	return newArchiveStorage(&zipWriter{zip.NewWriter(w)})
+}
+
+// CreateArchiveStorage creates the archive file and returns `*ArchiveStorage`
+// for it. Format is decided by the extension, `.tar.gz`, `.tgz` or `.zip`.
+// The file is closed by Close of the storage.
+func CreateArchiveStorage(path string) (*ArchiveStorage, error) {
+	var newStorage func(io.Writer) *ArchiveStorage
+	switch {
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		newStorage = NewTarGzStorage
+	case strings.HasSuffix(path, ".zip"):
+		newStorage = NewZipStorage
+	default:
+		return nil, ErrUnknownArchiveFormat
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	as := newStorage(f)
+	as.closer = f
+	return as, nil
+}
+
+func newArchiveStorage(aw archiveWriter) *ArchiveStorage {
+	as := &ArchiveStorage{aw: aw, index: newPathIndex()}
+	as.index.w = bufio.NewWriter(&as.indexBuf)
+	return as
+}
+
+// Save writes body of cr. If cr is a duplicate of another page,
+// body is not written and URL is recorded as an alias of that page.
+func (as *ArchiveStorage) Save(cr *crawler.CrawlResult) error {
+	if cr.NoIndex && as.SkipNoIndex {
+		return nil
+	}
+	contentType, ext := outputType(cr.ContentType, as.OutputFormat)
+	if cr.DuplicateOf != nil {
+		rel, err := as.index.alias(pageURL(cr), cr.DuplicateOf, contentType, ext)
+		if err != nil || !as.WriteMeta {
+			return err
+		}
+		as.mux.Lock()
+		defer as.mux.Unlock()
+		return as.writeManifest(newMeta(cr, rel))
+	}
+
+	body, err := convertBody(cr, as.OutputFormat)
+	if err != nil {
+		return err
+	}
+	rel, err := as.index.assignExt(pageURL(cr), contentType, ext)
+	if err != nil {
+		return err
+	}
+	tables, err := tableFiles(cr, as.Tables)
+	if err != nil {
+		return err
+	}
+
+	as.mux.Lock()
+	defer as.mux.Unlock()
+	err = as.aw.writeFile(rel, body, cr.FetchedAt)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if err := as.aw.writeFile(rel+t.suffix, t.body, cr.FetchedAt); err != nil {
+			return err
+		}
+	}
+	if !as.WriteMeta {
+		return nil
+	}
+
+	meta := newMeta(cr, rel)
+	b, err := marshalMeta(meta)
+	if err != nil {
+		return err
+	}
+	if err := as.aw.writeFile(rel+MetaSuffix, b, cr.FetchedAt); err != nil {
+		return err
+	}
+	return as.writeManifest(meta)
+}
+
+// writeManifest buffers a line of manifest. Caller must hold the lock.
+func (as *ArchiveStorage) writeManifest(meta *Meta) error {
+	line, err := manifestLine(meta)
+	if err != nil {
+		return err
+	}
+	as.manifest.Write(line)
+	return nil
+}
+
+// Close writes the index and manifest, and finalizes the archive.
+func (as *ArchiveStorage) Close() error {
+	as.mux.Lock()
+	defer as.mux.Unlock()
+
+	now := time.Now()
+	err := as.aw.writeFile(IndexFileName, as.indexBuf.Bytes(), now)
+	if err == nil && as.WriteMeta {
+		err = as.aw.writeFile(ManifestFileName, as.manifest.Bytes(), now)
+	}
+	if e := as.aw.Close(); err == nil {
+		err = e
+	}
+	if as.closer != nil {
+		if e := as.closer.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+type tarGzWriter struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+func (w *tarGzWriter) writeFile(name string, body []byte, modTime time.Time) error {
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(body)),
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.tw.Write(body)
+	return err
+}
+
+func (w *tarGzWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gw.Close()
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) writeFile(name string, body []byte, modTime time.Time) error {
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	fw, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(body)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
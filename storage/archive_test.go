@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/greytabby/grawl/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+var archiveTestPages = []string{
+	"https://test.com/",
+	"https://test.com/list?page=1",
+	"https://test.com/list?page=2",
+}
+
+func saveArchive(t *testing.T, as *ArchiveStorage) {
+	t.Helper()
+	as.WriteMeta = true
+	for _, v := range archiveTestPages {
+		u, _ := url.Parse(v)
+		assert.NoError(t, as.Save(&crawler.CrawlResult{URL: u, Body: []byte(v)}))
+	}
+	assert.NoError(t, as.Close())
+}
+
+var archiveTestWant = map[string]string{
+	"test_com/index.html":             "https://test.com/",
+	"test_com/list/index@page=1.html": "https://test.com/list?page=1",
+	"test_com/list/index@page=2.html": "https://test.com/list?page=2",
+}
+
+func TestTarGzStorage(t *testing.T) {
+	var buf bytes.Buffer
+	saveArchive(t, NewTarGzStorage(&buf))
+
+	gr, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gr)
+	files := map[string]string{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		b, err := ioutil.ReadAll(tr)
+		assert.NoError(t, err)
+		files[h.Name] = string(b)
+	}
+
+	for name, body := range archiveTestWant {
+		assert.Equal(t, body, files[name])
+		assert.Contains(t, files, name+MetaSuffix)
+	}
+	assert.Contains(t, files, IndexFileName)
+	assert.Contains(t, files, ManifestFileName)
+}
+
+func TestZipStorage(t *testing.T) {
+	var buf bytes.Buffer
+	saveArchive(t, NewZipStorage(&buf))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		b, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		r.Close()
+		files[f.Name] = string(b)
+	}
+
+	for name, body := range archiveTestWant {
+		assert.Equal(t, body, files[name])
+	}
+	assert.Contains(t, files, IndexFileName)
+}
+
+func TestCreateArchiveStorage(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+
+	for _, name := range []string{"result.tar.gz", "result.tgz", "result.zip"} {
+		as, err := CreateArchiveStorage(filepath.Join(tempDir, name))
+		assert.NoError(t, err)
+		saveArchive(t, as)
+		assert.FileExists(t, filepath.Join(tempDir, name))
+	}
+
+	_, err = CreateArchiveStorage(filepath.Join(tempDir, "result.rar"))
+	assert.Equal(t, ErrUnknownArchiveFormat, err)
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/greytabby/grawl/scrape"
+)
+
+// DiffLine is a line of text diff.
+type DiffLine struct {
+	// Op is '+' for added, '-' for removed and ' ' for common line.
+	Op   byte
+	Text string
+}
+
+func (dl DiffLine) String() string {
+	return string(dl.Op) + " " + dl.Text
+}
+
+// VisibleText returns lines of visible text of HTML body rendered by
+// scrape.DefaultTextOptions. Empty lines are omitted.
+func VisibleText(body []byte) ([]string, error) {
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0)
+	for _, l := range strings.Split(scrape.TextWithOptions(root, scrape.DefaultTextOptions), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines, nil
+}
+
+// DiffLines returns line diff to turn a into b.
+// Common lines are omitted unless context is true.
+// Removed lines are put before added lines in each change.
+func DiffLines(a, b []string, context bool) []DiffLine {
+	d := &differ{a: a, b: b}
+	d.diff(0, len(a), 0, len(b))
+
+	lines := make([]DiffLine, 0, len(d.lines))
+	added := make([]DiffLine, 0)
+	for _, l := range d.lines {
+		switch l.Op {
+		case '+':
+			added = append(added, l)
+			continue
+		case ' ':
+			lines = append(lines, added...)
+			added = added[:0]
+			if !context {
+				continue
+			}
+		}
+		lines = append(lines, l)
+	}
+	return append(lines, added...)
+}
+
+// differ finds the shortest edit script of a and b by the linear space
+// variation of Myers' O(ND) algorithm.
+type differ struct {
+	a, b  []string
+	lines []DiffLine
+}
+
+// diff appends the diff of a[a0:a1] and b[b0:b1] to lines.
+func (d *differ) diff(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.lines = append(d.lines, DiffLine{' ', d.a[a0]})
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a0 < a1-suffix && b0 < b1-suffix && d.a[a1-suffix-1] == d.b[b1-suffix-1] {
+		suffix++
+	}
+	a1, b1 = a1-suffix, b1-suffix
+
+	switch {
+	case a0 == a1:
+		for _, l := range d.b[b0:b1] {
+			d.lines = append(d.lines, DiffLine{'+', l})
+		}
+	case b0 == b1:
+		for _, l := range d.a[a0:a1] {
+			d.lines = append(d.lines, DiffLine{'-', l})
+		}
+	default:
+		x, y := d.split(a0, a1, b0, b1)
+		d.diff(a0, x, b0, y)
+		d.diff(x, a1, y, b1)
+	}
+
+	for _, l := range d.a[a1 : a1+suffix] {
+		d.lines = append(d.lines, DiffLine{' ', l})
+	}
+}
+
+// split returns the point in the middle of the shortest edit script of
+// a[a0:a1] and b[b0:b1], by searching it from both ends at once.
+// Both of them must not be empty.
+func (d *differ) split(a0, a1, b0, b1 int) (int, int) {
+	n, m := a1-a0, b1-b0
+	max := (n + m + 1) / 2
+	offset := max
+	// vf[offset+k] is the furthest x on the diagonal k = x-y from the start,
+	// and vb[offset+k] is the furthest x on the diagonal k from the end.
+	vf := make([]int, 2*max+2)
+	vb := make([]int, 2*max+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+	delta := n - m
+	front := delta%2 != 0
+	// Diagonals out of the grid are skipped by the start and end margins.
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	for step := 0; step < max; step++ {
+		for k := -step + fStart; k <= step-fEnd; k += 2 {
+			var x int
+			if k == -step || k != step && vf[offset+k-1] < vf[offset+k+1] {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a0+x] == d.b[b0+y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case front:
+				if i := offset + delta - k; i >= 0 && i < len(vb) && vb[i] != -1 && x >= n-vb[i] {
+					return a0 + x, b0 + y
+				}
+			}
+		}
+		for k := -step + bStart; k <= step-bEnd; k += 2 {
+			var x int
+			if k == -step || k != step && vb[offset+k-1] < vb[offset+k+1] {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a1-x-1] == d.b[b1-y-1] {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !front:
+				if i := offset + delta - k; i >= 0 && i < len(vf) && vf[i] != -1 && vf[i] >= n-x {
+					fx := vf[i]
+					return a0 + fx, b0 + fx - (delta - k)
+				}
+			}
+		}
+	}
+	// No common lines.
+	return a1, b0
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/greytabby/grawl/crawler"
+	"github.com/greytabby/grawl/scrape"
+)
+
+// Output formats of saved pages.
+const (
+	// FormatRaw saves the body as fetched.
+	FormatRaw = "raw"
+	// FormatMarkdown saves the main content of HTML pages as Markdown
+	// to `<path>.md`. Other pages are saved as fetched.
+	FormatMarkdown = "markdown"
+)
+
+// ErrUnknownOutputFormat is the error thrown if output format is unknown.
+var ErrUnknownOutputFormat = errors.New("Unknown output format")
+
+// CheckOutputFormat returns error if format is not an output format.
+// Empty format is the same as FormatRaw.
+func CheckOutputFormat(format string) error {
+	switch format {
+	case "", FormatRaw, FormatMarkdown:
+		return nil
+	}
+	return fmt.Errorf("%v: %s", ErrUnknownOutputFormat, format)
+}
+
+// outputType returns the content type of the page saved in format, and
+// the extension appended to its path. The extension is empty if the page
+// is not converted.
+func outputType(contentType, format string) (string, string) {
+	if format == FormatMarkdown && crawler.IsHTML(contentType) {
+		return "text/markdown", ".md"
+	}
+	return contentType, ""
+}
+
+// convertBody returns the body of cr converted to format.
+func convertBody(cr *crawler.CrawlResult, format string) ([]byte, error) {
+	if err := CheckOutputFormat(format); err != nil {
+		return nil, err
+	}
+	if _, ext := outputType(cr.ContentType, format); ext == "" {
+		return cr.Body, nil
+	}
+
+	root, err := cr.DOM()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(scrape.ExtractArticle(root).Markdown(cr.BaseURL())), nil
+}
+
+// Formats of tables extracted from saved pages.
+const (
+	// TablesCSV writes each table to `<path>.table<N>.csv`.
+	TablesCSV = "csv"
+	// TablesJSON writes all tables of a page to `<path>.tables.json`.
+	TablesJSON = "json"
+)
+
+var tableFileName = regexp.MustCompile(`\.(table[0-9]+\.csv|tables\.json)$`)
+
+// CheckTablesFormat returns error if format is not a tables format.
+// Empty format disables extraction of tables.
+func CheckTablesFormat(format string) error {
+	switch format {
+	case "", TablesCSV, TablesJSON:
+		return nil
+	}
+	return fmt.Errorf("%v: %s", ErrUnknownOutputFormat, format)
+}
+
+// sidecar is a file written next to a saved page.
+type sidecar struct {
+	// suffix is appended to the path of the page.
+	suffix string
+	body   []byte
+}
+
+// tableFiles returns files of tables in the HTML page.
+func tableFiles(cr *crawler.CrawlResult, format string) ([]sidecar, error) {
+	if err := CheckTablesFormat(format); err != nil {
+		return nil, err
+	}
+	if format == "" || !crawler.IsHTML(cr.ContentType) {
+		return nil, nil
+	}
+	root, err := cr.DOM()
+	if err != nil {
+		return nil, err
+	}
+	tables := scrape.Tables(root)
+	if len(tables) == 0 {
+		return nil, nil
+	}
+
+	if format == TablesJSON {
+		b, err := json.MarshalIndent(tables, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return []sidecar{{".tables.json", append(b, '\n')}}, nil
+	}
+
+	files := make([]sidecar, len(tables))
+	for i, t := range tables {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := w.WriteAll(append(t.Header, t.Rows...)); err != nil {
+			return nil, err
+		}
+		files[i] = sidecar{".table" + strconv.Itoa(i+1) + ".csv", buf.Bytes()}
+	}
+	return files, nil
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/greytabby/grawl/crawler"
+	"github.com/greytabby/grawl/scrape"
+)
+
+const (
+	// MetaSuffix is appended to the path of saved file to make
+	// the path of its metadata file.
+	MetaSuffix = ".meta.json"
+	// ManifestFileName is the name of the file that lists metadata
+	// of every saved page, one JSON object per line.
+	ManifestFileName = "manifest.jsonl"
+)
+
+// Meta is metadata of a saved page.
+type Meta struct {
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url,omitempty"`
+	Method   string `json:"method,omitempty"`
+	// FormData is the values posted by form submission.
+	FormData url.Values `json:"form_data,omitempty"`
+	// Path is the slash separated path of saved file relative to the base directory.
+	Path        string      `json:"path"`
+	ContentType string      `json:"content_type,omitempty"`
+	Depth       int         `json:"depth"`
+	Parent      string      `json:"parent,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"headers,omitempty"`
+	FetchedAt   time.Time   `json:"fetched_at"`
+	SHA256      string      `json:"sha256"`
+	// Canonical is the URL of `<link rel="canonical">`.
+	Canonical string `json:"canonical,omitempty"`
+	// NoIndex is true if the page has noindex robots directive.
+	NoIndex bool `json:"noindex,omitempty"`
+	// AliasOf is the URL of the page with the same body or canonical URL.
+	// The body is not saved again, and Path is the path of that page.
+	AliasOf string `json:"alias_of,omitempty"`
+	// Metadata is the structured metadata embedded in the page.
+	Metadata *scrape.Metadata `json:"metadata,omitempty"`
+}
+
+// newMeta returns metadata of cr saved to rel.
+func newMeta(cr *crawler.CrawlResult, rel string) *Meta {
+	sum := sha256.Sum256(cr.Body)
+	return &Meta{
+		URL:         cr.URL.String(),
+		FinalURL:    urlString(cr.FinalURL),
+		Method:      cr.Method,
+		FormData:    cr.FormData,
+		Path:        rel,
+		ContentType: cr.ContentType,
+		Depth:       cr.Depth,
+		Parent:      urlString(cr.Parent),
+		Status:      cr.StatusCode,
+		Header:      cr.Header,
+		FetchedAt:   cr.FetchedAt,
+		SHA256:      hex.EncodeToString(sum[:]),
+		Canonical:   urlString(cr.Canonical),
+		NoIndex:     cr.NoIndex,
+		AliasOf:     urlString(cr.DuplicateOf),
+		Metadata:    cr.Metadata,
+	}
+}
+
+// marshalMeta returns meta as indented JSON.
+func marshalMeta(meta *Meta) ([]byte, error) {
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// writeMetaFile writes meta as indented JSON to path.
+func writeMetaFile(path string, meta *Meta) error {
+	b, err := marshalMeta(meta)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// manifestLine returns meta without headers as a line of manifest.
+func manifestLine(meta *Meta) ([]byte, error) {
+	m := *meta
+	m.Header = nil
+	b, err := json.Marshal(&m)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// pageURL returns the URL that identifies the page of cr in the path index.
+// For POST results, the posted values are appended to the query so that
+// each submission is saved to its own file.
+func pageURL(cr *crawler.CrawlResult) *url.URL {
+	if cr.Method != http.MethodPost || len(cr.FormData) == 0 {
+		return cr.URL
+	}
+	u := *cr.URL
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += cr.FormData.Encode()
+	return &u
+}
+
+func urlString(URL *url.URL) string {
+	if URL == nil {
+		return ""
+	}
+	return URL.String()
+}
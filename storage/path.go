@@ -0,0 +1,392 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// IndexFileName is the name of the file that maps saved URLs to
+	// their paths relative to the base directory.
+	IndexFileName = "index.tsv"
+
+	// maxNameLength is the limit of a single path component.
+	// Most file systems limit file names to 255 bytes, leave some room
+	// for the collision suffix.
+	maxNameLength = 240
+	// maxPathLength is the limit of a path relative to the base directory.
+	maxPathLength = 1024
+	// hashLength is the number of hex digits used for shortened names.
+	hashLength = 16
+)
+
+var (
+	// ErrEmptyHost is the error thrown if URL does not have host.
+	ErrEmptyHost = errors.New("Empty host")
+	// ErrOutsideBaseDir is the error thrown if mapped path escapes
+	// from the base directory.
+	ErrOutsideBaseDir = errors.New("Outside of base directory")
+)
+
+// contentTypeExtensions maps media types to file extensions.
+// It is used instead of `mime.ExtensionsByType` because the result of
+// that depends on the mime database of the platform.
+var contentTypeExtensions = map[string]string{
+	"text/html":                ".html",
+	"application/xhtml+xml":    ".html",
+	"text/css":                 ".css",
+	"text/javascript":          ".js",
+	"application/javascript":   ".js",
+	"application/json":         ".json",
+	"application/ld+json":      ".json",
+	"text/xml":                 ".xml",
+	"application/xml":          ".xml",
+	"application/rss+xml":      ".rss",
+	"application/atom+xml":     ".atom",
+	"text/plain":               ".txt",
+	"text/markdown":            ".md",
+	"text/csv":                 ".csv",
+	"application/pdf":          ".pdf",
+	"application/zip":          ".zip",
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/svg+xml":            ".svg",
+	"image/webp":               ".webp",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"font/woff":                ".woff",
+	"font/woff2":               ".woff2",
+	"application/octet-stream": ".bin",
+}
+
+// windowsReservedNames can not be used as file name on Windows
+// regardless of extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// extensionByContentType returns file extension for the content type.
+// Empty content type is treated as HTML.
+func extensionByContentType(contentType string) string {
+	if contentType == "" {
+		return ".html"
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ".bin"
+	}
+	if ext, ok := contentTypeExtensions[mediaType]; ok {
+		return ext
+	}
+	return ".bin"
+}
+
+// urlToRelpath maps URL to a slash separated path relative to the base directory.
+//
+// The path is built as below:
+//   - host is lowercased, and written with `.` as `_` and `:` as `+`
+//   - every byte that is unsafe in file names is escaped as `%XX`
+//   - query is appended to the file name after `@`
+//   - empty path segment is written as `@`
+//   - path without extension is saved as `index` in the directory
+//     with the extension of the content type
+//
+// The mapping is not injective. For example, `/a` of HTML and `/a/index.html`
+// map to the same path. pathIndex resolves such collisions with `~N` suffix.
+func urlToRelpath(URL *url.URL, contentType string) (string, error) {
+	if URL.Host == "" {
+		return "", fmt.Errorf("%v: %s", ErrEmptyHost, URL)
+	}
+
+	segments := strings.Split(URL.EscapedPath(), "/")
+	if len(segments) > 0 && segments[0] == "" {
+		segments = segments[1:]
+	}
+	for i, s := range segments {
+		if unescaped, err := url.PathUnescape(s); err == nil {
+			segments[i] = unescaped
+		}
+	}
+
+	var dirs []string
+	name, ext := "index", extensionByContentType(contentType)
+	if len(segments) > 0 {
+		leaf := segments[len(segments)-1]
+		dirs = segments[:len(segments)-1]
+		if e := filepath.Ext(leaf); e != "" && e != leaf {
+			name, ext = strings.TrimSuffix(leaf, e), e
+		} else if leaf != "" {
+			dirs = segments
+		}
+	}
+
+	parts := make([]string, 0, len(dirs)+2)
+	parts = append(parts, shortenName(escapeHost(strings.ToLower(URL.Host)), ""))
+	for _, d := range dirs {
+		if d == "" {
+			parts = append(parts, "@")
+			continue
+		}
+		parts = append(parts, shortenName(escapeSegment(d), ""))
+	}
+
+	filename := escapeSegment(name)
+	if URL.RawQuery != "" {
+		filename += "@" + escapeSegment(URL.RawQuery)
+	}
+	escapedExt := escapeSegment(ext)
+	parts = append(parts, shortenName(filename, escapedExt)+escapedExt)
+
+	rel := strings.Join(parts, "/")
+	if len(rel) > maxPathLength {
+		rel = parts[0] + "/~" + hashString(rel) + escapedExt
+	}
+	return rel, nil
+}
+
+// escapeHost escapes host so that it can be used as directory name.
+func escapeHost(host string) string {
+	var b strings.Builder
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		switch {
+		case c == '.':
+			b.WriteByte('_')
+		case c == ':':
+			b.WriteByte('+')
+		case isAlnum(c) || c == '-':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// escapeSegment escapes every byte which is not safe on common file systems.
+// `.` and `..` are escaped so that they never point to other directories.
+func escapeSegment(s string) string {
+	if s == "." || s == ".." {
+		return strings.Repeat("%2E", len(s))
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAlnum(c) || strings.IndexByte("-._=,+!()", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	escaped := b.String()
+	base := strings.ToUpper(strings.SplitN(escaped, ".", 2)[0])
+	if windowsReservedNames[base] {
+		escaped = fmt.Sprintf("%%%02X", escaped[0]) + escaped[1:]
+	}
+	return escaped
+}
+
+// shortenName shortens name to fit maxNameLength with ext.
+// Shortened name ends with `~` and hash of the original name.
+func shortenName(name, ext string) string {
+	if len(name)+len(ext) <= maxNameLength {
+		return name
+	}
+	n := maxNameLength - len(ext) - hashLength - 1
+	if n < 0 {
+		n = 0
+	}
+	// Do not cut in the middle of the escape sequence.
+	if i := strings.LastIndexByte(name[:n], '%'); i >= 0 && i+3 > n {
+		n = i
+	}
+	return name[:n] + "~" + hashString(name)
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
+
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// pathIndex assigns unique paths to URLs and records them to the index file.
+// A path already used by another URL, or a path of metadata or table file, is
+// suffixed with `~N`, which never appears in the output of `urlToRelpath`.
+type pathIndex struct {
+	mux   sync.Mutex
+	paths map[string]string // path to URL
+	urls  map[string]string // URL to path
+	w     *bufio.Writer
+	f     *os.File
+}
+
+// openPathIndex loads existing entries of the index file and opens it for append.
+func openPathIndex(path string) (*pathIndex, error) {
+	pi := newPathIndex()
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		// Aliases share the path with the original URL written before.
+		if _, ok := pi.paths[fields[1]]; !ok {
+			pi.paths[fields[1]] = fields[0]
+		}
+		pi.urls[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	pi.f = f
+	pi.w = bufio.NewWriter(f)
+	return pi, nil
+}
+
+func newPathIndex() *pathIndex {
+	return &pathIndex{
+		paths: map[string]string{},
+		urls:  map[string]string{},
+	}
+}
+
+// assign returns the unique relative path for URL.
+func (pi *pathIndex) assign(URL *url.URL, contentType string) (string, error) {
+	return pi.assignExt(URL, contentType, "")
+}
+
+// assignExt returns the unique relative path for URL with ext appended,
+// unless the path already ends with ext.
+func (pi *pathIndex) assignExt(URL *url.URL, contentType, ext string) (string, error) {
+	rawURL := URL.String()
+
+	pi.mux.Lock()
+	defer pi.mux.Unlock()
+	if rel, ok := pi.urls[rawURL]; ok {
+		return rel, nil
+	}
+
+	rel, err := urlToRelpath(URL, contentType)
+	if err != nil {
+		return "", err
+	}
+	if ext != "" && extOf(rel) != ext {
+		rel += ext
+	}
+	if pi.used(rel) {
+		ext := extOf(rel)
+		base := strings.TrimSuffix(rel, ext)
+		for n := 2; ; n++ {
+			candidate := base + "~" + strconv.Itoa(n) + ext
+			if !pi.used(candidate) {
+				rel = candidate
+				break
+			}
+		}
+	}
+
+	if err := pi.record(rawURL, rel); err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+// alias records URL as an alias of orig, and returns the path of orig.
+func (pi *pathIndex) alias(URL, orig *url.URL, contentType, ext string) (string, error) {
+	rel, err := pi.assignExt(orig, contentType, ext)
+	if err != nil {
+		return "", err
+	}
+
+	rawURL := URL.String()
+	pi.mux.Lock()
+	defer pi.mux.Unlock()
+	if _, ok := pi.urls[rawURL]; ok {
+		return rel, nil
+	}
+	pi.urls[rawURL] = rel
+	return rel, pi.write(rawURL, rel)
+}
+
+// used reports whether rel can not be assigned. Caller must hold the lock.
+func (pi *pathIndex) used(rel string) bool {
+	if strings.HasSuffix(rel, MetaSuffix) || tableFileName.MatchString(rel) {
+		return true
+	}
+	_, ok := pi.paths[rel]
+	return ok
+}
+
+// record adds URL and path to the index. Caller must hold the lock.
+func (pi *pathIndex) record(rawURL, rel string) error {
+	pi.paths[rel] = rawURL
+	pi.urls[rawURL] = rel
+	return pi.write(rawURL, rel)
+}
+
+// write appends an entry to the index file. Caller must hold the lock.
+func (pi *pathIndex) write(rawURL, rel string) error {
+	if pi.w == nil {
+		return nil
+	}
+	if _, err := fmt.Fprintf(pi.w, "%s\t%s\n", rawURL, rel); err != nil {
+		return err
+	}
+	return pi.w.Flush()
+}
+
+// Close closes the index file.
+func (pi *pathIndex) Close() error {
+	if pi.f == nil {
+		return nil
+	}
+	return pi.f.Close()
+}
+
+// extOf returns extension of the last component of slash separated path.
+func extOf(rel string) string {
+	name := rel[strings.LastIndexByte(rel, '/')+1:]
+	if i := strings.LastIndexByte(name, '.'); i > 0 {
+		return name[i:]
+	}
+	return ""
+}
+
+// joinBaseDir joins slash separated rel to baseDir and makes sure
+// that the result is inside baseDir.
+func joinBaseDir(baseDir, rel string) (string, error) {
+	path := filepath.Join(baseDir, filepath.FromSlash(rel))
+	r, err := filepath.Rel(filepath.Clean(baseDir), path)
+	if err != nil {
+		return "", err
+	}
+	if r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) || filepath.IsAbs(r) {
+		return "", fmt.Errorf("%v: %s", ErrOutsideBaseDir, rel)
+	}
+	return path, nil
+}
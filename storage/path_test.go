@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLToRelpath(t *testing.T) {
+	testCases := []struct {
+		url         string
+		contentType string
+		want        string
+	}{
+		{"https://test.com", "", "test_com/index.html"},
+		{"https://test.com/", "text/html; charset=utf-8", "test_com/index.html"},
+		{"https://test.com/users/file", "text/html", "test_com/users/file/index.html"},
+		{"https://test.com:8080/file", "", "test_com+8080/file/index.html"},
+		{"https://Test.COM/Path", "", "test_com/Path/index.html"},
+		{"https://test.com/list?page=1", "", "test_com/list/index@page=1.html"},
+		{"https://test.com/list?page=1&sort=asc", "", "test_com/list/index@page=1%26sort=asc.html"},
+		{"https://test.com/style.css?v=1.0", "text/css", "test_com/style@v=1.0.css"},
+		{"https://test.com/image.png", "image/png", "test_com/image.png"},
+		{"https://test.com/api/items", "application/json", "test_com/api/items/index.json"},
+		{"https://test.com/download", "application/x-unknown", "test_com/download/index.bin"},
+		{"https://test.com/../etc/passwd", "", "test_com/%2E%2E/etc/passwd/index.html"},
+		{"https://test.com/a//b", "", "test_com/a/@/b/index.html"},
+		{"https://test.com/a%2Fb", "", "test_com/a%2Fb/index.html"},
+		{"https://test.com/what%3F%20is", "", "test_com/what%3F%20is/index.html"},
+		{"https://test.com/con", "", "test_com/%63on/index.html"},
+		{"https://test.com/x#fragment", "", "test_com/x/index.html"},
+	}
+
+	for _, tt := range testCases {
+		u, err := url.Parse(tt.url)
+		assert.NoError(t, err)
+		got, err := urlToRelpath(u, tt.contentType)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.url)
+	}
+}
+
+func TestURLToRelpathLengthLimit(t *testing.T) {
+	u, _ := url.Parse("https://test.com/" + strings.Repeat("a", 300) + "?" + strings.Repeat("q", 300))
+	got, err := urlToRelpath(u, "")
+	assert.NoError(t, err)
+	for _, name := range strings.Split(got, "/") {
+		assert.True(t, len(name) <= maxNameLength, name)
+	}
+	assert.True(t, strings.HasSuffix(got, ".html"))
+
+	u, _ = url.Parse("https://test.com" + strings.Repeat("/"+strings.Repeat("b", 200), 10))
+	got, err = urlToRelpath(u, "")
+	assert.NoError(t, err)
+	assert.True(t, len(got) <= maxPathLength)
+	assert.True(t, strings.HasPrefix(got, "test_com/~"))
+}
+
+func TestURLToRelpathEmptyHost(t *testing.T) {
+	u, _ := url.Parse("/relative")
+	_, err := urlToRelpath(u, "")
+	assert.Error(t, err)
+}
+
+func TestJoinBaseDir(t *testing.T) {
+	_, err := joinBaseDir("/tmp/base", "../outside")
+	assert.Error(t, err)
+	got, err := joinBaseDir("/tmp/base", "test_com/index.html")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/base/test_com/index.html", got)
+}
+
+func TestPathIndexAssign(t *testing.T) {
+	pi := newPathIndex()
+	root, _ := url.Parse("https://test.com/")
+	index, _ := url.Parse("https://test.com/index.html")
+	again, _ := url.Parse("https://test.com/index.html")
+
+	p1, err := pi.assign(root, "")
+	assert.NoError(t, err)
+	p2, err := pi.assign(index, "")
+	assert.NoError(t, err)
+	p3, err := pi.assign(again, "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "test_com/index.html", p1)
+	assert.Equal(t, "test_com/index~2.html", p2)
+	assert.Equal(t, p2, p3)
+}
+
+func TestPathIndexAssignReserved(t *testing.T) {
+	pi := newPathIndex()
+	tests := []struct {
+		rawURL   string
+		expected string
+	}{
+		{"https://test.com/a.meta.json", "test_com/a.meta~2.json"},
+		{"https://test.com/a.tables.json", "test_com/a.tables~2.json"},
+		{"https://test.com/a.table1.csv", "test_com/a.table1~2.csv"},
+		{"https://test.com/a.tablex.csv", "test_com/a.tablex.csv"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.rawURL)
+		got, err := pi.assign(u, "")
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, got)
+	}
+
+	md, _ := url.Parse("https://test.com/page.html")
+	got, err := pi.assignExt(md, "text/markdown", ".md")
+	assert.NoError(t, err)
+	assert.Equal(t, "test_com/page.html.md", got)
+}
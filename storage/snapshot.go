@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/greytabby/grawl/crawler"
+)
+
+const (
+	// SnapshotsDirName is the directory under the base directory
+	// that has one directory per snapshot.
+	SnapshotsDirName = "snapshots"
+	// ObjectsDirName is the directory under the base directory
+	// that has bodies named by their SHA-256.
+	ObjectsDirName = "objects"
+)
+
+// SnapshotStorage saves crawl results as a dated snapshot.
+//
+//	BaseDir/snapshots/<Name>/manifest.jsonl
+//	BaseDir/objects/<first 2 digits of SHA-256>/<SHA-256>
+//
+// Bodies are content-addressed, so a body is stored only once
+// however many snapshots have it.
+type SnapshotStorage struct {
+	BaseDir string
+	// Name is the name of the snapshot. By default, it is the date
+	// of the first save. If the snapshot already exists, time is appended.
+	Name string
+	// SkipNoIndex skips pages with noindex robots directive.
+	SkipNoIndex bool
+
+	manifest *os.File
+	mux      sync.Mutex
+	once     sync.Once
+	err      error
+}
+
+// NewSnapshotStorage returns `*SnapshotStorage`.
+func NewSnapshotStorage(baseDir string) *SnapshotStorage {
+	return &SnapshotStorage{BaseDir: baseDir}
+}
+
+// Dir returns the directory of the snapshot.
+func (ss *SnapshotStorage) Dir() string {
+	return filepath.Join(ss.BaseDir, SnapshotsDirName, ss.Name)
+}
+
+func (ss *SnapshotStorage) Save(cr *crawler.CrawlResult) error {
+	if cr.NoIndex && ss.SkipNoIndex {
+		return nil
+	}
+	if err := ss.open(); err != nil {
+		return err
+	}
+
+	meta := newMeta(cr, "")
+	meta.Path = objectPath(meta.SHA256)
+	if err := ss.writeObject(meta.Path, cr.Body); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+	_, err = ss.manifest.Write(append(b, '\n'))
+	return err
+}
+
+// Close closes the manifest of the snapshot.
+func (ss *SnapshotStorage) Close() error {
+	if ss.manifest == nil {
+		return nil
+	}
+	return ss.manifest.Close()
+}
+
+func (ss *SnapshotStorage) open() error {
+	ss.once.Do(func() {
+		now := time.Now()
+		if ss.Name == "" {
+			ss.Name = now.Format("2006-01-02")
+			if _, err := os.Stat(ss.Dir()); err == nil {
+				ss.Name = now.Format("2006-01-02T150405")
+			}
+		}
+		if ss.err = os.MkdirAll(ss.Dir(), 0755); ss.err != nil {
+			return
+		}
+		ss.manifest, ss.err = os.OpenFile(filepath.Join(ss.Dir(), ManifestFileName),
+			os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	})
+	return ss.err
+}
+
+// writeObject writes body to rel unless it already exists.
+// Body is written to a temporary file and renamed, so that
+// concurrent writes of the same body never see a partial file.
+func (ss *SnapshotStorage) writeObject(rel string, body []byte) error {
+	path := filepath.Join(ss.BaseDir, filepath.FromSlash(rel))
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// objectPath returns the path of the body relative to the base directory.
+func objectPath(sum string) string {
+	return ObjectsDirName + "/" + sum[:2] + "/" + sum
+}
+
+// Snapshot is a saved snapshot.
+type Snapshot struct {
+	// BaseDir is the base directory that has the snapshot and the bodies.
+	BaseDir string
+	// Name is the name of the snapshot.
+	Name string
+	// Dir is the directory of the snapshot.
+	Dir string
+	// Pages is metadata of saved pages by URL.
+	Pages map[string]*Meta
+}
+
+// ErrNotSnapshot is the error thrown if the directory is not a snapshot
+// in BaseDir/snapshots.
+var ErrNotSnapshot = errors.New("Not a snapshot directory")
+
+// SplitSnapshotDir returns the base directory and the name of the snapshot
+// of dir, such as "BaseDir/snapshots/2020-05-01".
+func SplitSnapshotDir(dir string) (baseDir, name string, err error) {
+	dir = filepath.Clean(dir)
+	parent, name := filepath.Split(dir)
+	parent = filepath.Clean(parent)
+	if name == "" || filepath.Base(parent) != SnapshotsDirName {
+		return "", "", fmt.Errorf("%v: %s", ErrNotSnapshot, dir)
+	}
+	return filepath.Dir(parent), name, nil
+}
+
+// OpenSnapshot reads the manifest of the snapshot of the name in baseDir.
+func OpenSnapshot(baseDir, name string) (*Snapshot, error) {
+	dir := filepath.Join(baseDir, SnapshotsDirName, name)
+	f, err := os.Open(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &Snapshot{BaseDir: baseDir, Name: name, Dir: dir, Pages: map[string]*Meta{}}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		meta := new(Meta)
+		if err := json.Unmarshal(scanner.Bytes(), meta); err != nil {
+			return nil, err
+		}
+		s.Pages[meta.URL] = meta
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Body returns the saved body of the page.
+func (s *Snapshot) Body(meta *Meta) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(s.BaseDir, filepath.FromSlash(meta.Path)))
+}
+
+// SnapshotDiff is the difference of two snapshots.
+// Each field has sorted URLs.
+type SnapshotDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// DiffSnapshots compares pages of snapshot a and b.
+// A page is changed if SHA-256 of the body is different.
+func DiffSnapshots(a, b *Snapshot) *SnapshotDiff {
+	d := new(SnapshotDiff)
+	for u, ma := range a.Pages {
+		mb, ok := b.Pages[u]
+		if !ok {
+			d.Removed = append(d.Removed, u)
+			continue
+		}
+		if ma.SHA256 != mb.SHA256 {
+			d.Changed = append(d.Changed, u)
+		}
+	}
+	for u := range b.Pages {
+		if _, ok := a.Pages[u]; !ok {
+			d.Added = append(d.Added, u)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
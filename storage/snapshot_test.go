@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/greytabby/grawl/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+func saveSnapshot(t *testing.T, baseDir, name string, pages map[string]string) *Snapshot {
+	t.Helper()
+	ss := NewSnapshotStorage(baseDir)
+	ss.Name = name
+	for u, body := range pages {
+		URL, _ := url.Parse(u)
+		assert.NoError(t, ss.Save(&crawler.CrawlResult{URL: URL, Body: []byte(body), ContentType: "text/html"}))
+	}
+	assert.NoError(t, ss.Close())
+	s, err := OpenSnapshot(baseDir, name)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestSnapshotStorage(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+
+	a := saveSnapshot(t, tempDir, "2020-05-01", map[string]string{
+		"https://test.com/":        "<p>same</p>",
+		"https://test.com/changed": "<p>first</p><p>second</p>",
+		"https://test.com/removed": "<p>removed</p>",
+	})
+	b := saveSnapshot(t, tempDir, "2020-05-02", map[string]string{
+		"https://test.com/":        "<p>same</p>",
+		"https://test.com/changed": "<p>first</p><p>third</p>",
+		"https://test.com/added":   "<p>same</p>",
+	})
+
+	// Same bodies are stored once.
+	objects, err := filepath.Glob(filepath.Join(tempDir, ObjectsDirName, "*", "*"))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(objects))
+
+	body, err := b.Body(b.Pages["https://test.com/added"])
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>same</p>", string(body))
+
+	d := DiffSnapshots(a, b)
+	assert.Equal(t, []string{"https://test.com/added"}, d.Added)
+	assert.Equal(t, []string{"https://test.com/removed"}, d.Removed)
+	assert.Equal(t, []string{"https://test.com/changed"}, d.Changed)
+}
+
+func TestSnapshotStorageDefaultName(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+
+	first := NewSnapshotStorage(tempDir)
+	URL, _ := url.Parse("https://test.com/")
+	assert.NoError(t, first.Save(&crawler.CrawlResult{URL: URL, Body: []byte("body")}))
+	assert.NoError(t, first.Close())
+	second := NewSnapshotStorage(tempDir)
+	assert.NoError(t, second.Save(&crawler.CrawlResult{URL: URL, Body: []byte("body")}))
+	assert.NoError(t, second.Close())
+
+	assert.NotEmpty(t, first.Name)
+	assert.NotEqual(t, first.Name, second.Name)
+}
+
+func TestSplitSnapshotDir(t *testing.T) {
+	baseDir, name, err := SplitSnapshotDir(filepath.Join("out", SnapshotsDirName, "2020-05-01") + string(filepath.Separator))
+	assert.NoError(t, err)
+	assert.Equal(t, "out", baseDir)
+	assert.Equal(t, "2020-05-01", name)
+
+	_, _, err = SplitSnapshotDir(filepath.Join("out", "2020-05-01"))
+	assert.Error(t, err)
+}
+
+func TestVisibleText(t *testing.T) {
+	got, err := VisibleText([]byte(`<html><head><title>Title</title><style>p {}</style></head>
+<body><p>first</p><script>var a;</script><p>second <b>bold</b></p>
+<div hidden>hidden</div><span aria-hidden="true">icon</span><p style="display: none">none</p>
+<ul><li>one</li><li>two</li></ul></body></html>`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Title", "first", "second bold", "one", "two"}, got)
+}
+
+func TestDiffLines(t *testing.T) {
+	a := []string{"a", "b", "c", "d"}
+	b := []string{"a", "c", "e", "d"}
+
+	got := DiffLines(a, b, false)
+	assert.Equal(t, []DiffLine{{'-', "b"}, {'+', "e"}}, got)
+
+	got = DiffLines(a, b, true)
+	assert.Equal(t, []DiffLine{{' ', "a"}, {'-', "b"}, {' ', "c"}, {'+', "e"}, {' ', "d"}}, got)
+
+	got = DiffLines([]string{"a", "b"}, []string{"c", "d"}, false)
+	assert.Equal(t, []DiffLine{{'-', "a"}, {'-', "b"}, {'+', "c"}, {'+', "d"}}, got)
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	a := make([]string, 20000)
+	b := make([]string, 20000)
+	for i := range a {
+		a[i] = fmt.Sprintf("line %d", i)
+		b[i] = a[i]
+	}
+	b[100] = "changed"
+	b = append(b[:5000], b[5001:]...)
+	b = append(b, "added")
+
+	got := DiffLines(a, b, false)
+	assert.Equal(t, []DiffLine{{'-', "line 100"}, {'+', "changed"}, {'-', "line 5000"}, {'+', "added"}}, got)
+}
@@ -5,42 +5,165 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
 
 	"github.com/greytabby/grawl/crawler"
 )
 
+// Storage saves crawl results.
+type Storage interface {
+	Save(*crawler.CrawlResult) error
+	// Close flushes and closes files opened by Save.
+	Close() error
+}
+
+// FileStorage saves crawl results under BaseDir.
+// Paths of saved files are recorded to `index.tsv` in BaseDir.
 type FileStorage struct {
 	BaseDir string
+	// WriteMeta enables writing metadata of each saved file to
+	// `<file>.meta.json` and `manifest.jsonl` in BaseDir.
+	WriteMeta bool
+	// OutputFormat is the format of saved pages, FormatRaw or FormatMarkdown.
+	// By default, pages are saved as fetched.
+	OutputFormat string
+	// Tables is the format of tables extracted from HTML pages, TablesCSV
+	// or TablesJSON. By default, tables are not extracted.
+	Tables string
+	// SkipNoIndex skips pages with noindex robots directive.
+	SkipNoIndex bool
+
+	index    *pathIndex
+	manifest *os.File
+	mux      sync.Mutex
+	once     sync.Once
+	err      error
 }
 
 func NewFileStorage(baseDir string) *FileStorage {
-	return &FileStorage{baseDir}
+	return &FileStorage{BaseDir: baseDir}
 }
 
+// Save writes body of cr. If cr is a duplicate of another page,
+// body is not written and URL is recorded as an alias of that page.
 func (fs *FileStorage) Save(cr *crawler.CrawlResult) error {
-	path := fs.urlToFilepath(cr.URL)
-	err := os.MkdirAll(filepath.Dir(filepath.Clean(path)), 0755)
+	if cr.NoIndex && fs.SkipNoIndex {
+		return nil
+	}
+	if cr.DuplicateOf != nil {
+		return fs.saveAlias(cr)
+	}
+	body, err := convertBody(cr, fs.OutputFormat)
+	if err != nil {
+		return err
+	}
+	if err := fs.open(); err != nil {
+		return err
+	}
+	contentType, ext := outputType(cr.ContentType, fs.OutputFormat)
+	rel, err := fs.index.assignExt(pageURL(cr), contentType, ext)
+	if err != nil {
+		return err
+	}
+	path, err := joinBaseDir(fs.BaseDir, rel)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filepath.Clean(path)), 0755)
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(path, []byte(cr.Body), 0644)
+	err = ioutil.WriteFile(path, body, 0644)
 	if err != nil {
 		return err
 	}
+	tables, err := tableFiles(cr, fs.Tables)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if err := ioutil.WriteFile(path+t.suffix, t.body, 0644); err != nil {
+			return err
+		}
+	}
+	if fs.WriteMeta {
+		return fs.saveMeta(newMeta(cr, rel), path)
+	}
 	return nil
 }
 
-func (fs *FileStorage) urlToFilepath(URL *url.URL) string {
-	if URL.Host == "" {
-		return ""
+func (fs *FileStorage) saveAlias(cr *crawler.CrawlResult) error {
+	if err := fs.open(); err != nil {
+		return err
+	}
+	contentType, ext := outputType(cr.ContentType, fs.OutputFormat)
+	rel, err := fs.index.alias(pageURL(cr), cr.DuplicateOf, contentType, ext)
+	if err != nil || !fs.WriteMeta {
+		return err
+	}
+	return fs.writeManifest(newMeta(cr, rel))
+}
+
+// Close closes the index and manifest files.
+func (fs *FileStorage) Close() error {
+	var err error
+	if fs.index != nil {
+		err = fs.index.Close()
+	}
+	if fs.manifest != nil {
+		if e := fs.manifest.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (fs *FileStorage) saveMeta(meta *Meta, path string) error {
+	if err := writeMetaFile(path+MetaSuffix, meta); err != nil {
+		return err
+	}
+	return fs.writeManifest(meta)
+}
+
+func (fs *FileStorage) writeManifest(meta *Meta) error {
+	line, err := manifestLine(meta)
+	if err != nil {
+		return err
 	}
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	_, err = fs.manifest.Write(line)
+	return err
+}
 
-	host := strings.ReplaceAll(URL.Host, ":", "-") // port number
-	host = strings.ReplaceAll(host, ".", "_")      // host
-	path := filepath.Join(fs.BaseDir, host, URL.Path)
-	if filepath.Ext(filepath.Base(path)) == "" {
-		path = filepath.Join(path, "index.html")
+func (fs *FileStorage) open() error {
+	fs.once.Do(func() {
+		if err := os.MkdirAll(filepath.Clean(fs.BaseDir), 0755); err != nil {
+			fs.err = err
+			return
+		}
+		fs.index, fs.err = openPathIndex(filepath.Join(fs.BaseDir, IndexFileName))
+		if fs.err != nil || !fs.WriteMeta {
+			return
+		}
+		fs.manifest, fs.err = os.OpenFile(filepath.Join(fs.BaseDir, ManifestFileName),
+			os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	})
+	return fs.err
+}
+
+// assign returns the path of URL relative to BaseDir.
+func (fs *FileStorage) assign(URL *url.URL, contentType string) (string, error) {
+	if err := fs.open(); err != nil {
+		return "", err
+	}
+	return fs.index.assign(URL, contentType)
+}
+
+func (fs *FileStorage) urlToFilepath(URL *url.URL, contentType string) (string, error) {
+	rel, err := fs.assign(URL, contentType)
+	if err != nil {
+		return "", err
 	}
-	return path
+	return joinBaseDir(fs.BaseDir, rel)
 }
@@ -1,12 +1,18 @@
 package storage
 
 import (
+	"encoding/json"
 	"io/ioutil"
-	"testing"
+	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
 
 	"github.com/greytabby/grawl/crawler"
+	"github.com/greytabby/grawl/scrape"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,7 +31,7 @@ func TestSave(t *testing.T) {
 	crawlResults := make([]*crawler.CrawlResult, len(visited))
 	for i, v := range visited {
 		urls[i], _ = url.Parse(v)
-		crawlResults[i] = &crawler.CrawlResult{URL: urls[i], Body: v, Links: []string{}}
+		crawlResults[i] = &crawler.CrawlResult{URL: urls[i], Body: []byte(v), Links: []crawler.Link{}}
 	}
 
 	tempDir, err := ioutil.TempDir("", "test")
@@ -34,8 +40,271 @@ func TestSave(t *testing.T) {
 	for _, v := range crawlResults {
 		err = storage.Save(v)
 		assert.NoError(t, err)
-		path := storage.urlToFilepath(v.URL)
+		path, err := storage.urlToFilepath(v.URL, v.ContentType)
+		assert.NoError(t, err)
 		assert.FileExists(t, path)
 		t.Log("Path:", path)
 	}
-}
\ No newline at end of file
+	assert.NoError(t, storage.Close())
+	assert.FileExists(t, filepath.Join(tempDir, IndexFileName))
+}
+
+func TestSaveDoesNotOverwrite(t *testing.T) {
+	visited := []string{
+		"https://test.com/list?page=1",
+		"https://test.com/list?page=2",
+		"https://test.com/list",
+		"https://test.com/list/",
+		"https://test.com/",
+		"https://test.com/index.html",
+		"https://test.com/../../etc/passwd",
+		"https://test.com/a%2F..%2F..%2Fb",
+	}
+
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+	storage := NewFileStorage(tempDir)
+	paths := map[string]bool{}
+	for _, v := range visited {
+		u, _ := url.Parse(v)
+		err = storage.Save(&crawler.CrawlResult{URL: u, Body: []byte(v), Links: []crawler.Link{}})
+		assert.NoError(t, err)
+		path, err := storage.urlToFilepath(u, "")
+		assert.NoError(t, err)
+		assert.False(t, paths[path], "path %s is used twice", path)
+		paths[path] = true
+
+		body, err := ioutil.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, v, string(body))
+		rel, err := filepath.Rel(tempDir, path)
+		assert.NoError(t, err)
+		assert.False(t, strings.HasPrefix(rel, ".."))
+	}
+	assert.NoError(t, storage.Close())
+
+	// Reopened storage keeps the mapping.
+	storage = NewFileStorage(tempDir)
+	defer storage.Close()
+	for _, v := range visited {
+		u, _ := url.Parse(v)
+		path, err := storage.urlToFilepath(u, "")
+		assert.NoError(t, err)
+		assert.True(t, paths[path])
+	}
+}
+
+func TestSaveWithMeta(t *testing.T) {
+	u, _ := url.Parse("https://test.com/page?id=1")
+	parent, _ := url.Parse("https://test.com/")
+	fetchedAt := time.Date(2020, 5, 1, 15, 0, 0, 0, time.UTC)
+	cr := &crawler.CrawlResult{
+		URL:        u,
+		Body:       []byte("body"),
+		Links:      []crawler.Link{},
+		FinalURL:   u,
+		Depth:      2,
+		Parent:     parent,
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		FetchedAt:  fetchedAt,
+		Metadata:   &scrape.Metadata{OpenGraph: map[string][]string{"title": {"Title"}}},
+	}
+
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+	storage := NewFileStorage(tempDir)
+	storage.WriteMeta = true
+	assert.NoError(t, storage.Save(cr))
+	assert.NoError(t, storage.Close())
+
+	path, err := storage.urlToFilepath(u, "")
+	assert.NoError(t, err)
+	b, err := ioutil.ReadFile(path + MetaSuffix)
+	assert.NoError(t, err)
+	var meta Meta
+	assert.NoError(t, json.Unmarshal(b, &meta))
+	assert.Equal(t, "https://test.com/page?id=1", meta.URL)
+	assert.Equal(t, "https://test.com/", meta.Parent)
+	assert.Equal(t, "test_com/page/index@id=1.html", meta.Path)
+	assert.Equal(t, 2, meta.Depth)
+	assert.Equal(t, 200, meta.Status)
+	assert.Equal(t, "text/html", meta.Header.Get("Content-Type"))
+	assert.True(t, fetchedAt.Equal(meta.FetchedAt))
+	assert.Equal(t, "230d8358dc8e8890b4c58deeb62912ee2f20357ae92a5cc861b98e68fe31acb5", meta.SHA256)
+	if assert.NotNil(t, meta.Metadata) {
+		assert.Equal(t, []string{"Title"}, meta.Metadata.OpenGraph["title"])
+	}
+
+	b, err = ioutil.ReadFile(filepath.Join(tempDir, ManifestFileName))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Equal(t, 1, len(lines))
+	var line Meta
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, meta.URL, line.URL)
+	assert.Nil(t, line.Header)
+}
+
+func TestSaveDuplicate(t *testing.T) {
+	orig, _ := url.Parse("https://test.com/page")
+	dup, _ := url.Parse("https://test.com/page?session=1")
+
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+	storage := NewFileStorage(tempDir)
+	storage.WriteMeta = true
+	// The duplicate may be saved before the original.
+	assert.NoError(t, storage.Save(&crawler.CrawlResult{URL: dup, Body: []byte("body"), DuplicateOf: orig}))
+	assert.NoError(t, storage.Save(&crawler.CrawlResult{URL: orig, Body: []byte("body")}))
+	assert.NoError(t, storage.Close())
+
+	_, err = os.Stat(filepath.Join(tempDir, "test_com", "page", "index@session=1.html"))
+	assert.True(t, os.IsNotExist(err))
+	assert.FileExists(t, filepath.Join(tempDir, "test_com", "page", "index.html"))
+
+	b, err := ioutil.ReadFile(filepath.Join(tempDir, IndexFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "https://test.com/page?session=1\ttest_com/page/index.html\n")
+
+	b, err = ioutil.ReadFile(filepath.Join(tempDir, ManifestFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"alias_of":"https://test.com/page"`)
+}
+
+func TestSaveMarkdown(t *testing.T) {
+	body := `<html><head><title>Post</title></head><body>
+<nav><a href="/">Home</a></nav>
+<article><p>The main content of the post, long enough to be found, with a <a href="next">link</a>.</p></article>
+</body></html>`
+	pages := []struct {
+		rawURL, contentType, body, path string
+	}{
+		{"https://test.com/post", "text/html; charset=utf-8", body, "test_com/post/index.md"},
+		{"https://test.com/page.html", "text/html", body, "test_com/page.html.md"},
+		{"https://test.com/style.css", "text/css", "p {}", "test_com/style.css"},
+	}
+
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+	storage := NewFileStorage(tempDir)
+	storage.OutputFormat = FormatMarkdown
+	for _, p := range pages {
+		u, _ := url.Parse(p.rawURL)
+		assert.NoError(t, storage.Save(&crawler.CrawlResult{URL: u, Body: []byte(p.body), ContentType: p.contentType}))
+	}
+	assert.NoError(t, storage.Close())
+
+	b, err := ioutil.ReadFile(filepath.Join(tempDir, "test_com", "post", "index.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# Post\n\nThe main content of the post, long enough to be found, with a [link](https://test.com/next).\n", string(b))
+	assert.FileExists(t, filepath.Join(tempDir, "test_com", "page.html.md"))
+	b, err = ioutil.ReadFile(filepath.Join(tempDir, "test_com", "style.css"))
+	assert.NoError(t, err)
+	assert.Equal(t, "p {}", string(b))
+
+	index, err := ioutil.ReadFile(filepath.Join(tempDir, IndexFileName))
+	assert.NoError(t, err)
+	for _, p := range pages {
+		assert.Contains(t, string(index), p.rawURL+"\t"+p.path+"\n")
+	}
+
+	storage.OutputFormat = "pdf"
+	u, _ := url.Parse("https://test.com/other")
+	assert.Error(t, storage.Save(&crawler.CrawlResult{URL: u, Body: []byte(body)}))
+}
+
+func TestSaveTables(t *testing.T) {
+	body := `<table><tr><th>Name</th><th>Price</th></tr><tr><td>A, B</td><td>1</td></tr></table>
+<table><tr><td>x</td></tr></table>`
+	u, _ := url.Parse("https://test.com/prices")
+	cr := &crawler.CrawlResult{URL: u, Body: []byte(body), ContentType: "text/html"}
+
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+	storage := NewFileStorage(filepath.Join(tempDir, "csv"))
+	storage.Tables = TablesCSV
+	assert.NoError(t, storage.Save(cr))
+	assert.NoError(t, storage.Close())
+
+	dir := filepath.Join(tempDir, "csv", "test_com", "prices")
+	b, err := ioutil.ReadFile(filepath.Join(dir, "index.html.table1.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Name,Price\n\"A, B\",1\n", string(b))
+	b, err = ioutil.ReadFile(filepath.Join(dir, "index.html.table2.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "x\n", string(b))
+
+	storage = NewFileStorage(filepath.Join(tempDir, "json"))
+	storage.Tables = TablesJSON
+	assert.NoError(t, storage.Save(cr))
+	assert.NoError(t, storage.Close())
+
+	b, err = ioutil.ReadFile(filepath.Join(tempDir, "json", "test_com", "prices", "index.html.tables.json"))
+	assert.NoError(t, err)
+	var tables []scrape.Table
+	assert.NoError(t, json.Unmarshal(b, &tables))
+	if assert.Len(t, tables, 2) {
+		assert.Equal(t, [][]string{{"Name", "Price"}}, tables[0].Header)
+		assert.Equal(t, [][]string{{"A, B", "1"}}, tables[0].Rows)
+	}
+}
+
+func TestSavePostResult(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+	storage := NewFileStorage(tempDir)
+	storage.WriteMeta = true
+	u, _ := url.Parse("https://test.com/login")
+	results := []*crawler.CrawlResult{
+		{URL: u, Body: []byte("get"), ContentType: "text/html", Method: "GET"},
+		{URL: u, Body: []byte("post"), ContentType: "text/html", Method: "POST", FormData: url.Values{"user": {"guest"}}},
+	}
+	for _, cr := range results {
+		assert.NoError(t, storage.Save(cr))
+	}
+	assert.NoError(t, storage.Close())
+
+	index, err := ioutil.ReadFile(filepath.Join(tempDir, IndexFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(index), "https://test.com/login\t")
+	assert.Contains(t, string(index), "https://test.com/login?user=guest\t")
+
+	manifest, err := ioutil.ReadFile(filepath.Join(tempDir, ManifestFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(manifest), `"method":"POST","form_data":{"user":["guest"]}`)
+}
+
+func TestSaveSkipNoIndex(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir) // tier down
+	storage := NewFileStorage(tempDir)
+	storage.WriteMeta = true
+	u1, _ := url.Parse("https://test.com/noindex")
+	u2, _ := url.Parse("https://test.com/page")
+	canonical, _ := url.Parse("https://test.com/canonical")
+	assert.NoError(t, storage.Save(&crawler.CrawlResult{URL: u1, Body: []byte("noindex"), NoIndex: true}))
+	assert.NoError(t, storage.Save(&crawler.CrawlResult{URL: u2, Body: []byte("page"), Canonical: canonical}))
+	storage.SkipNoIndex = true
+	u3, _ := url.Parse("https://test.com/skipped")
+	assert.NoError(t, storage.Save(&crawler.CrawlResult{URL: u3, Body: []byte("skipped"), NoIndex: true}))
+	assert.NoError(t, storage.Close())
+
+	assert.FileExists(t, filepath.Join(tempDir, "test_com", "noindex", "index.html"))
+	assert.FileExists(t, filepath.Join(tempDir, "test_com", "page", "index.html"))
+	_, err = os.Stat(filepath.Join(tempDir, "test_com", "skipped"))
+	assert.True(t, os.IsNotExist(err))
+
+	manifest, err := ioutil.ReadFile(filepath.Join(tempDir, ManifestFileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(manifest), `"noindex":true`)
+	assert.Contains(t, string(manifest), `"canonical":"https://test.com/canonical"`)
+	assert.NotContains(t, string(manifest), "skipped")
+}